@@ -0,0 +1,355 @@
+package kdlgo
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"math/big"
+	"net"
+	"net/mail"
+	"net/url"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// AnnotationConverter converts a value with a type annotation into the Go
+// value the annotation stands for, or returns why the value doesn't satisfy
+// it.
+type AnnotationConverter func(value KDLValue) (interface{}, error)
+
+// AnnotationRegistry maps type annotations to the converters for the values
+// annotated with them. It is safe for concurrent use.
+type AnnotationRegistry struct {
+	mu         sync.RWMutex
+	converters map[string]AnnotationConverter
+}
+
+// NewAnnotationRegistry returns a registry holding converters for the type
+// annotations reserved by the KDL spec:
+//
+//	i8, i16, i32, i64, isize    int8, int16, int32, int64, int
+//	u8, u16, u32, u64, usize    uint8, uint16, uint32, uint64, uint
+//	f32, f64                    float32, float64
+//	decimal64, decimal128       *big.Rat
+//	date-time, date, time       time.Time
+//	duration                    time.Duration, from an ISO 8601 duration
+//	decimal                     *big.Rat, from a string
+//	ipv4, ipv6                  net.IP
+//	url, url-reference          *url.URL
+//	irl, irl-reference          *url.URL
+//	uuid                        [16]byte
+//	regex                       *regexp.Regexp
+//	base64                      []byte
+//	email                       *mail.Address
+//	currency, country-2, country-3, country-subdivision, hostname
+//	                            string, after checking its format
+func NewAnnotationRegistry() *AnnotationRegistry {
+	registry := &AnnotationRegistry{converters: map[string]AnnotationConverter{}}
+	for annotation, converter := range builtinAnnotations() {
+		registry.Register(annotation, converter)
+	}
+	return registry
+}
+
+// DefaultAnnotations is the registry used by KDLValue.Convert and by
+// Unmarshal.
+var DefaultAnnotations = NewAnnotationRegistry()
+
+// RegisterAnnotation adds a converter to DefaultAnnotations.
+func RegisterAnnotation(annotation string, converter AnnotationConverter) {
+	DefaultAnnotations.Register(annotation, converter)
+}
+
+// Register adds the converter for an annotation, replacing any converter it
+// already had.
+func (registry *AnnotationRegistry) Register(annotation string, converter AnnotationConverter) {
+	registry.mu.Lock()
+	defer registry.mu.Unlock()
+	registry.converters[annotation] = converter
+}
+
+func (registry *AnnotationRegistry) lookup(annotation string) (AnnotationConverter, bool) {
+	registry.mu.RLock()
+	defer registry.mu.RUnlock()
+	converter, ok := registry.converters[annotation]
+	return converter, ok
+}
+
+// Convert returns the Go value for a value according to its type annotation.
+// Values without an annotation, or with one that isn't registered, are
+// returned as a bool, string, nil, int64, *big.Int or float64. The error is an
+// *AnnotationError if the value doesn't satisfy its annotation.
+func (registry *AnnotationRegistry) Convert(value KDLValue) (interface{}, error) {
+	converter, ok := registry.lookup(value.declaredType)
+	if !ok || value.Type == KDLNullType {
+		return genericValue(value), nil
+	}
+
+	converted, err := converter(value)
+	if err != nil {
+		return nil, &AnnotationError{
+			Annotation: value.declaredType,
+			Line:       value.span.Start.Line,
+			Column:     value.span.Start.Column,
+			Err:        err,
+		}
+	}
+	return converted, nil
+}
+
+// Validate checks that every annotated argument and property in the document
+// satisfies its annotation, returning the error for the first one that
+// doesn't.
+func (registry *AnnotationRegistry) Validate(doc *Document) error {
+	return registry.validateNodes(doc.nodes)
+}
+
+func (registry *AnnotationRegistry) validateNodes(nodes []*Node) error {
+	for _, node := range nodes {
+		for _, arg := range node.args {
+			_, err := registry.Convert(arg)
+			if err != nil {
+				return err
+			}
+		}
+		for _, prop := range node.props {
+			_, err := registry.Convert(prop.value)
+			if err != nil {
+				return err
+			}
+		}
+		err := registry.validateNodes(node.children)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Convert returns the Go value for the value according to its type annotation
+// using DefaultAnnotations.
+func (kdlValue KDLValue) Convert() (interface{}, error) {
+	return DefaultAnnotations.Convert(kdlValue)
+}
+
+func builtinAnnotations() map[string]AnnotationConverter {
+	return map[string]AnnotationConverter{
+		"i8":    intConverter(reflect.TypeOf(int8(0))),
+		"i16":   intConverter(reflect.TypeOf(int16(0))),
+		"i32":   intConverter(reflect.TypeOf(int32(0))),
+		"i64":   intConverter(reflect.TypeOf(int64(0))),
+		"isize": intConverter(reflect.TypeOf(int(0))),
+		"u8":    intConverter(reflect.TypeOf(uint8(0))),
+		"u16":   intConverter(reflect.TypeOf(uint16(0))),
+		"u32":   intConverter(reflect.TypeOf(uint32(0))),
+		"u64":   intConverter(reflect.TypeOf(uint64(0))),
+		"usize": intConverter(reflect.TypeOf(uint(0))),
+
+		"f32":        convertF32,
+		"f64":        convertF64,
+		"decimal64":  convertDecimalNumber,
+		"decimal128": convertDecimalNumber,
+
+		"date-time": timeConverter(time.RFC3339Nano),
+		"date":      timeConverter("2006-01-02"),
+		"time":      timeConverter("15:04:05.999999999"),
+		"duration":  stringConverter(parseISODuration),
+		"decimal": stringConverter(func(s string) (interface{}, error) {
+			rat, ok := new(big.Rat).SetString(s)
+			if !ok {
+				return nil, errors.New("not a decimal number")
+			}
+			return rat, nil
+		}),
+
+		"ipv4":          ipConverter(false),
+		"ipv6":          ipConverter(true),
+		"url":           urlConverter(true),
+		"url-reference": urlConverter(false),
+		"irl":           urlConverter(true),
+		"irl-reference": urlConverter(false),
+		"uuid":          stringConverter(parseUUID),
+		"regex": stringConverter(func(s string) (interface{}, error) {
+			return regexp.Compile(s)
+		}),
+		"base64": stringConverter(func(s string) (interface{}, error) {
+			return base64.StdEncoding.DecodeString(s)
+		}),
+		"email": stringConverter(func(s string) (interface{}, error) {
+			return mail.ParseAddress(s)
+		}),
+
+		"currency":            patternConverter(`^[A-Z]{3}$`),
+		"country-2":           patternConverter(`^[A-Z]{2}$`),
+		"country-3":           patternConverter(`^[A-Z]{3}$`),
+		"country-subdivision": patternConverter(`^[A-Z]{2}-[A-Z0-9]{1,3}$`),
+		"hostname":            patternConverter(`^(?i:[a-z0-9](?:[a-z0-9-]{0,61}[a-z0-9])?)(?:\.(?i:[a-z0-9](?:[a-z0-9-]{0,61}[a-z0-9])?))*\.?$`),
+	}
+}
+
+var (
+	errNotInteger = errors.New("not an integer")
+	errNotNumber  = errors.New("not a number")
+	errNotString  = errors.New("not a string")
+	errOutOfRange = errors.New("out of range")
+)
+
+// intConverter converts integers to t, which has to be one of Go's integer
+// types, if they are in its range.
+func intConverter(t reflect.Type) AnnotationConverter {
+	return func(value KDLValue) (interface{}, error) {
+		integer, ok := value.BigInt()
+		if !ok {
+			return nil, errNotInteger
+		}
+
+		v := reflect.New(t).Elem()
+		switch t.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			if !integer.IsInt64() || v.OverflowInt(integer.Int64()) {
+				return nil, errOutOfRange
+			}
+			v.SetInt(integer.Int64())
+		default:
+			if !integer.IsUint64() || v.OverflowUint(integer.Uint64()) {
+				return nil, errOutOfRange
+			}
+			v.SetUint(integer.Uint64())
+		}
+		return v.Interface(), nil
+	}
+}
+
+func convertF32(value KDLValue) (interface{}, error) {
+	if value.Type != KDLNumberType {
+		return nil, errNotNumber
+	}
+	f, ok := fitFloat(value, 32)
+	if !ok {
+		return nil, errOutOfRange
+	}
+	return float32(f), nil
+}
+
+func convertF64(value KDLValue) (interface{}, error) {
+	if value.Type != KDLNumberType {
+		return nil, errNotNumber
+	}
+	f, ok := fitFloat(value, 64)
+	if !ok {
+		return nil, errOutOfRange
+	}
+	return f, nil
+}
+
+func convertDecimalNumber(value KDLValue) (interface{}, error) {
+	rat, ok := value.BigRat()
+	if !ok && value.decimal != nil {
+		return nil, errOutOfRange
+	}
+	if !ok {
+		return nil, errNotNumber
+	}
+	return rat, nil
+}
+
+// stringConverter returns a converter for annotations that only apply to
+// strings.
+func stringConverter(convert func(s string) (interface{}, error)) AnnotationConverter {
+	return func(value KDLValue) (interface{}, error) {
+		if value.Type != KDLStringType && value.Type != KDLRawStringType {
+			return nil, errNotString
+		}
+		s, _ := value.ToString()
+		return convert(s)
+	}
+}
+
+func timeConverter(layout string) AnnotationConverter {
+	return stringConverter(func(s string) (interface{}, error) {
+		return time.Parse(layout, s)
+	})
+}
+
+func ipConverter(isV6 bool) AnnotationConverter {
+	return stringConverter(func(s string) (interface{}, error) {
+		ip := net.ParseIP(s)
+		if ip == nil || strings.Contains(s, ":") != isV6 {
+			return nil, errors.New("not an IP address of the right version")
+		}
+		return ip, nil
+	})
+}
+
+func urlConverter(isAbsolute bool) AnnotationConverter {
+	return stringConverter(func(s string) (interface{}, error) {
+		u, err := url.Parse(s)
+		if err != nil {
+			return nil, err
+		}
+		if isAbsolute && !u.IsAbs() {
+			return nil, errors.New("not an absolute URL")
+		}
+		return u, nil
+	})
+}
+
+func patternConverter(pattern string) AnnotationConverter {
+	re := regexp.MustCompile(pattern)
+	return stringConverter(func(s string) (interface{}, error) {
+		if !re.MatchString(s) {
+			return nil, errors.New("doesn't have the right format")
+		}
+		return s, nil
+	})
+}
+
+var uuidPattern = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+
+func parseUUID(s string) (interface{}, error) {
+	var uuid [16]byte
+	if !uuidPattern.MatchString(s) {
+		return nil, errors.New("not a UUID")
+	}
+	_, err := hex.Decode(uuid[:], []byte(strings.ReplaceAll(s, "-", "")))
+	return uuid, err
+}
+
+var isoDuration = regexp.MustCompile(`^([+-])?P(?:(\d+)W|(?:(\d+)D)?(?:T(?:(\d+)H)?(?:(\d+)M)?(?:(\d+(?:[.,]\d+)?)S)?)?)$`)
+
+// parseISODuration parses an ISO 8601 duration such as "P1DT2H30M". Years and
+// months are rejected as they don't have a fixed length.
+func parseISODuration(s string) (interface{}, error) {
+	match := isoDuration.FindStringSubmatch(s)
+	if match == nil || strings.HasSuffix(s, "P") || strings.HasSuffix(s, "T") {
+		return nil, errors.New("not an ISO 8601 duration without years or months")
+	}
+
+	units := []time.Duration{0, 0, 7 * 24 * time.Hour, 24 * time.Hour, time.Hour, time.Minute}
+	var total time.Duration
+	for i := 2; i < len(units); i++ {
+		if match[i] == "" {
+			continue
+		}
+		n, err := strconv.ParseInt(match[i], 10, 64)
+		if err != nil {
+			return nil, err
+		}
+		total += time.Duration(n) * units[i]
+	}
+	if match[6] != "" {
+		seconds, err := strconv.ParseFloat(strings.Replace(match[6], ",", ".", 1), 64)
+		if err != nil {
+			return nil, err
+		}
+		total += time.Duration(seconds * float64(time.Second))
+	}
+	if match[1] == "-" {
+		total = -total
+	}
+	return total, nil
+}
@@ -0,0 +1,144 @@
+package kdlgo
+
+import (
+	"errors"
+	"math/big"
+	"net"
+	"net/url"
+	"reflect"
+	"regexp"
+	"testing"
+	"time"
+)
+
+func TestAnnotationConvert(t *testing.T) {
+	doc, err := ParseDocumentString(`node (u8)255 (i16)-300 (f32)1.5 (decimal64)0.1 (date-time)"2021-03-04T05:06:07Z" (date)"2021-03-04" (time)"05:06:07.5" (duration)"P1DT2H30M1.5S" (ipv4)"10.0.0.1" (ipv6)"::1" (url)"https://example.com/a" (uuid)"123e4567-e89b-12d3-a456-426614174000" (base64)"aGk=" (regex)"^a+$" (currency)"EUR" (unknown)"kept" 42`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	link, _ := url.Parse("https://example.com/a")
+	expected := []interface{}{
+		uint8(255),
+		int16(-300),
+		float32(1.5),
+		big.NewRat(1, 10),
+		time.Date(2021, 3, 4, 5, 6, 7, 0, time.UTC),
+		time.Date(2021, 3, 4, 0, 0, 0, 0, time.UTC),
+		time.Date(0, 1, 1, 5, 6, 7, 500000000, time.UTC),
+		26*time.Hour + 30*time.Minute + 1500*time.Millisecond,
+		net.ParseIP("10.0.0.1"),
+		net.ParseIP("::1"),
+		link,
+		[16]byte{0x12, 0x3e, 0x45, 0x67, 0xe8, 0x9b, 0x12, 0xd3, 0xa4, 0x56, 0x42, 0x66, 0x14, 0x17, 0x40, 0x00},
+		[]byte("hi"),
+		regexp.MustCompile("^a+$"),
+		"EUR",
+		"kept",
+		int64(42),
+	}
+	for i, arg := range doc.GetNodes()[0].GetArgs() {
+		converted, err := arg.Convert()
+		if err != nil {
+			t.Errorf("Failed to convert argument %d: %v", i, err)
+			continue
+		}
+		if !reflect.DeepEqual(converted, expected[i]) {
+			t.Errorf("Expected %#v but got %#v", expected[i], converted)
+		}
+	}
+}
+
+func TestAnnotationErrors(t *testing.T) {
+	inputs := []string{
+		`(u8)256`, `(u8)-1`, `(i8)1.5`, `(i32)"1"`, `(f32)1e39`,
+		`(f64)1e400`, `(f64)1e999999999`, `(f64)1e2147483647`, `(f32)1e2147483647`,
+		`(date-time)"yesterday"`, `(duration)"P1Y"`, `(duration)"PT"`,
+		`(ipv4)"::1"`, `(ipv6)"10.0.0.1"`, `(url)"relative/path"`,
+		`(uuid)"not-a-uuid"`, `(base64)"!!"`, `(regex)"("`, `(currency)"euro"`,
+	}
+	for _, input := range inputs {
+		doc, err := ParseDocumentString("node " + input)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = DefaultAnnotations.Validate(doc)
+		var annotationErr *AnnotationError
+		if !errors.As(err, &annotationErr) || !errors.Is(err, KDLInvalidAnnotation) {
+			t.Errorf("Expected %s to be invalid, got %v", input, err)
+			continue
+		}
+		if annotationErr.Line != 1 || annotationErr.Column != 6 {
+			t.Errorf("Unexpected position for %s: %v", input, err)
+		}
+	}
+
+	doc, err := ParseDocumentString("node (f64)#inf (f32)#-inf", WithVersion(KDLVersion2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := DefaultAnnotations.Validate(doc); err != nil {
+		t.Error("Expected #inf and #-inf to be valid floats, got", err)
+	}
+}
+
+func TestAnnotationRegistry(t *testing.T) {
+	registry := NewAnnotationRegistry()
+	registry.Register("even", func(value KDLValue) (interface{}, error) {
+		n, ok := value.Int64()
+		if !ok || n%2 != 0 {
+			return nil, errors.New("not even")
+		}
+		return n, nil
+	})
+
+	doc, err := ParseDocumentString("parent {\n    child (even)2 key=(even)3\n}")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = registry.Validate(doc)
+	if !errors.Is(err, KDLInvalidAnnotation) {
+		t.Error("Expected the custom annotation to be checked, got", err)
+	}
+	if err = DefaultAnnotations.Validate(doc); err != nil {
+		t.Error("Expected unregistered annotations to be ignored, got", err)
+	}
+}
+
+func TestUnmarshalAnnotations(t *testing.T) {
+	type config struct {
+		Started time.Time     `kdl:"started"`
+		Timeout time.Duration `kdl:"timeout"`
+		Link    *url.URL      `kdl:"link"`
+		Level   int           `kdl:"level"`
+		Any     interface{}   `kdl:"any"`
+	}
+
+	var c config
+	err := Unmarshal([]byte(`started (date)"2021-03-04"
+timeout (duration)"PT1M"
+link (url)"https://example.com"
+level (u8)3
+any (ipv4)"10.0.0.1"
+`), &c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	link, _ := url.Parse("https://example.com")
+	expected := config{
+		Started: time.Date(2021, 3, 4, 0, 0, 0, 0, time.UTC),
+		Timeout: time.Minute,
+		Link:    link,
+		Level:   3,
+		Any:     net.ParseIP("10.0.0.1"),
+	}
+	if !reflect.DeepEqual(c, expected) {
+		t.Errorf("Expected:\n%#v\nGot:\n%#v", expected, c)
+	}
+
+	err = Unmarshal([]byte("level (u8)300"), &c)
+	var unmarshalErr *UnmarshalError
+	if !errors.As(err, &unmarshalErr) || unmarshalErr.Kind != KDLInvalidAnnotation || unmarshalErr.Path != "level" {
+		t.Error("Expected the annotation to be checked, got", err)
+	}
+}
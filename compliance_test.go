@@ -0,0 +1,88 @@
+package kdlgo
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// The compliance fixtures follow the layout of the kdl-org test suite: every
+// file in input/ that is valid KDL has a file of the same name in
+// expected_kdl/ holding its canonical form, and every file without one has to
+// fail to parse.
+const (
+	complianceInputDir    = "tests/test_cases/input"
+	complianceExpectedDir = "tests/test_cases/expected_kdl"
+)
+
+// canonicalFormat prints documents the way the expected_kdl files are written.
+var canonicalFormat = FormatOptions{SortProps: true, DedupeProps: true, RawStringsAsStrings: true}
+
+// knownComplianceFailures lists the fixtures this parser doesn't handle yet
+// along with the reason. They are reported as skipped and the test fails once
+// one of them passes so that it gets removed from here.
+var knownComplianceFailures = map[string]string{}
+
+func TestCompliance(t *testing.T) {
+	files, err := ioutil.ReadDir(complianceInputDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	total, failed, skipped := 0, 0, 0
+	for _, file := range files {
+		name := file.Name()
+		if !strings.HasSuffix(name, ".kdl") {
+			continue
+		}
+
+		total++
+		ok := t.Run(strings.TrimSuffix(name, ".kdl"), func(t *testing.T) {
+			reason, isKnown := knownComplianceFailures[name]
+			failure := checkCompliance(name)
+			switch {
+			case isKnown && failure == "":
+				t.Error("Passes now, remove it from knownComplianceFailures")
+			case isKnown:
+				skipped++
+				t.Skip("Known failure (" + reason + "): " + failure)
+			case failure != "":
+				t.Error(failure)
+			}
+		})
+		if !ok {
+			failed++
+		}
+	}
+	t.Logf("%d of %d fixtures pass, %d failed, %d known failures", total-failed-skipped, total, failed, skipped)
+}
+
+// checkCompliance parses a fixture and returns why it doesn't match its
+// expected output, or an empty string if it does.
+func checkCompliance(name string) string {
+	doc, parseErr := ParseDocumentFile(filepath.Join(complianceInputDir, name), WithVersion(KDLVersion1))
+	expected, err := ioutil.ReadFile(filepath.Join(complianceExpectedDir, name))
+	if os.IsNotExist(err) {
+		if parseErr == nil {
+			return "Expected parsing to fail"
+		}
+		return ""
+	}
+	if err != nil {
+		return err.Error()
+	}
+	if parseErr != nil {
+		return "Failed to parse: " + parseErr.Error()
+	}
+
+	s, err := doc.Format(canonicalFormat)
+	if err != nil {
+		return "Failed to format: " + err.Error()
+	}
+	if s != string(expected) {
+		return "Expected:\n" + string(expected) + "\nGot:\n" + s
+	}
+	return ""
+}
@@ -1,13 +1,13 @@
 package kdlgo
 
 import (
+	"errors"
+	"io"
 	"strings"
 	"unicode"
 )
 
 const (
-	eof = "EOF"
-
 	asterisk   = '*'
 	backslash  = '\\'
 	dash       = '-'
@@ -36,7 +36,7 @@ func parseObjects(kdlr *kdlReader, hasOpen bool, key string) (KDLObjects, error)
 			if obj != nil {
 				objects = append(objects, obj)
 			}
-		} else if err.Error() == eof || err.Error() == kdlEndOfObj {
+		} else if errors.Is(err, io.EOF) || errors.Is(err, kdlEndOfObj) {
 			if obj != nil {
 				objects = append(objects, obj)
 			}
@@ -66,7 +66,7 @@ func parseObject(kdlr *kdlReader) (KDLObject, error) {
 
 		skipLine, err := lineComment(kdlr)
 		if err != nil {
-			if err.Error() == eof && skipLine {
+			if errors.Is(err, io.EOF) && skipLine {
 				return nil, nil
 			}
 			return nil, err
@@ -92,7 +92,7 @@ func parseObject(kdlr *kdlReader) (KDLObject, error) {
 	key, err := parseKey(kdlr)
 
 	if err != nil {
-		if err.Error() == kdlKeyOnly {
+		if errors.Is(err, kdlKeyOnly) {
 			return NewKDLDefault(key), nil
 		}
 		return nil, err
@@ -101,12 +101,12 @@ func parseObject(kdlr *kdlReader) (KDLObject, error) {
 	var objects []KDLObject
 	for {
 		err = blockComment(kdlr)
-		if err != nil && err.Error() != eof {
+		if err != nil && !errors.Is(err, io.EOF) {
 			return nil, err
 		}
 
 		r, err := kdlr.readRune()
-		if err != nil && err.Error() != eof {
+		if err != nil && !errors.Is(err, io.EOF) {
 			return nil, err
 		}
 
@@ -119,7 +119,7 @@ func parseObject(kdlr *kdlReader) (KDLObject, error) {
 		}
 
 		if r == newline || r == semicolon ||
-			(err != nil && err.Error() == eof) {
+			(err != nil && errors.Is(err, io.EOF)) {
 			if len(objects) == 0 {
 				return NewKDLDefault(key), nil
 			} else if len(objects) == 1 {
@@ -136,7 +136,7 @@ func parseObject(kdlr *kdlReader) (KDLObject, error) {
 		if skipNext {
 			r, err = kdlr.peek()
 			if err != nil {
-				if err.Error() == eof {
+				if errors.Is(err, io.EOF) {
 					return ConvertToDocument(objects)
 				}
 				return nil, err
@@ -145,7 +145,7 @@ func parseObject(kdlr *kdlReader) (KDLObject, error) {
 
 		skipLine, err := lineComment(kdlr)
 		if err != nil {
-			if err.Error() == eof && skipLine {
+			if errors.Is(err, io.EOF) && skipLine {
 				return ConvertToDocument(objects)
 			}
 			return nil, err
@@ -157,7 +157,7 @@ func parseObject(kdlr *kdlReader) (KDLObject, error) {
 
 		obj, err := parseVal(kdlr, key, r)
 		if err != nil {
-			if err.Error() == kdlEndOfObj {
+			if errors.Is(err, kdlEndOfObj) {
 				return ConvertToDocument(objects)
 			}
 			return nil, err
@@ -176,7 +176,7 @@ func parseKey(kdlr *kdlReader) (string, error) {
 	for {
 		r, err := kdlr.readRune()
 		if err != nil {
-			if err.Error() == eof {
+			if errors.Is(err, io.EOF) {
 				return checkQuotedString(key), keyOnlyErr()
 			}
 			return key.String(), err
@@ -216,18 +216,18 @@ func parseKey(kdlr *kdlReader) (string, error) {
 
 func parseVal(kdlr *kdlReader, key string, r rune) (KDLObject, error) {
 	value, err := parseValue(kdlr, key, r)
-	if err == nil || err.Error() == KDLInvalidNumValue {
+	if err == nil || errors.Is(err, KDLInvalidNumValue) || errors.Is(err, KDLInvalidEscape) {
 		return value, err
 	}
 
-	if err.Error() == kdlEndOfObj {
+	if errors.Is(err, kdlEndOfObj) {
 		return value, err
 	}
 
 	node, err := parseKey(kdlr)
 
-	if err != nil && err.Error() != KDLInvalidKeyChar {
-		if err.Error() == kdlKeyOnly {
+	if err != nil && !errors.Is(err, KDLInvalidKeyChar) {
+		if errors.Is(err, kdlKeyOnly) {
 			return NewKDLObjects(key, []KDLObject{NewKDLDefault(node)}), nil
 		}
 		return nil, err
@@ -263,7 +263,7 @@ func parseValue(kdlr *kdlReader, key string, r rune) (KDLObject, error) {
 		}
 	}
 
-	if unicode.IsNumber(r) {
+	if unicode.IsNumber(r) || isSignedNumber(kdlr, r) {
 		return parseNumber(kdlr, key)
 	}
 
@@ -289,11 +289,19 @@ func parseValue(kdlr *kdlReader, key string, r rune) (KDLObject, error) {
 	return nil, invalidSyntaxErr()
 }
 
+func isSignedNumber(kdlr *kdlReader, r rune) bool {
+	if r != '+' && r != dash {
+		return false
+	}
+	bytes, err := kdlr.peekX(2)
+	return err == nil && unicode.IsNumber(rune(bytes[1]))
+}
+
 func lineComment(kdlr *kdlReader) (bool, error) {
 	skipLine, _ := kdlr.isNext([]byte{slash, slash})
 	if skipLine {
 		err := kdlr.discardLine()
-		if err != nil && err.Error() != eof {
+		if err != nil && !errors.Is(err, io.EOF) {
 			return false, err
 		}
 		return true, err
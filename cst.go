@@ -0,0 +1,438 @@
+package kdlgo
+
+import (
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// CSTDocument is a concrete syntax tree of a document. Unlike Document, it
+// keeps every byte it was parsed from: the whitespace, comments, slashdashed
+// elements and terminators around each node and entry are kept as trivia
+// attached to it. Printing an unmodified CSTDocument reproduces its input
+// exactly, and editing it only changes the parts that were edited.
+type CSTDocument struct {
+	nodes    []*CSTNode
+	trailing string
+	version  KDLVersion
+}
+
+// CSTNode is a node of a CSTDocument. Its leading trivia is everything between
+// the previous node and this one, including the previous node's terminator.
+// Its trailing trivia is the whitespace after it, up to its own terminator.
+type CSTNode struct {
+	leading  string
+	prefix   string
+	nameText string
+	entries  []*CSTEntry
+	children *cstChildren
+	trailing string
+
+	name         string
+	declaredType string
+	version      KDLVersion
+}
+
+type cstChildren struct {
+	// leading is the trivia between the entries and the opening brace and
+	// trailing the trivia between the last child and the closing brace.
+	leading  string
+	nodes    []*CSTNode
+	trailing string
+}
+
+// CSTEntry is an argument or property of a CSTNode along with the trivia in
+// front of it.
+type CSTEntry struct {
+	leading string
+	text    string
+
+	key     string
+	isProp  bool
+	value   KDLValue
+	version KDLVersion
+}
+
+// cstIndent is the indentation added for the children of nodes that don't
+// have any yet.
+const cstIndent = "    "
+
+func newCSTDocument(src string, doc *Document) *CSTDocument {
+	builder := &cstBuilder{src: src, version: doc.version}
+	nodes, end := builder.nodes(doc.nodes, 0)
+	return &CSTDocument{nodes: nodes, trailing: src[end:], version: doc.version}
+}
+
+// cstBuilder slices the source of a parsed document up according to the spans
+// the parser recorded. Anything that falls between them is trivia.
+type cstBuilder struct {
+	src     string
+	version KDLVersion
+}
+
+// nodes builds the siblings that start at offset start and returns them along
+// with the offset the last one ends at.
+func (builder *cstBuilder) nodes(nodes []*Node, start int) ([]*CSTNode, int) {
+	var cstNodes []*CSTNode
+	for _, node := range nodes {
+		cstNodes = append(cstNodes, builder.node(node, start))
+		start = node.trailingEnd.Offset
+	}
+	return cstNodes, start
+}
+
+func (builder *cstBuilder) node(node *Node, start int) *CSTNode {
+	src := builder.src
+	cstNode := &CSTNode{
+		leading:      src[start:node.span.Start.Offset],
+		prefix:       src[node.span.Start.Offset:node.nameSpan.Start.Offset],
+		nameText:     src[node.nameSpan.Start.Offset:node.nameSpan.End.Offset],
+		name:         node.name,
+		declaredType: node.declaredType,
+		version:      builder.version,
+	}
+
+	end := node.nameSpan.End.Offset
+	for _, entry := range builder.entries(node) {
+		entry.leading = src[end:entry.start]
+		end = entry.end
+		cstNode.entries = append(cstNode.entries, entry.CSTEntry)
+	}
+
+	if !node.childrenSpan.IsZero() {
+		open := node.childrenSpan.Start.Offset
+		closing := node.childrenSpan.End.Offset - 1
+		children, childrenEnd := builder.nodes(node.children, open+1)
+		cstNode.children = &cstChildren{
+			leading:  src[end:open],
+			nodes:    children,
+			trailing: src[childrenEnd:closing],
+		}
+		end = closing + 1
+	}
+	cstNode.trailing = src[end:node.trailingEnd.Offset]
+	return cstNode
+}
+
+type spannedEntry struct {
+	*CSTEntry
+	start int
+	end   int
+}
+
+// entries returns the arguments and properties of the node in the order they
+// appear in the source.
+func (builder *cstBuilder) entries(node *Node) []spannedEntry {
+	var entries []spannedEntry
+	for _, arg := range node.args {
+		entries = append(entries, builder.entry(arg.span, "", false, arg))
+	}
+	for _, prop := range node.props {
+		entries = append(entries, builder.entry(prop.span, prop.key, true, prop.value))
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].start < entries[j].start
+	})
+	return entries
+}
+
+func (builder *cstBuilder) entry(span Span, key string, isProp bool, value KDLValue) spannedEntry {
+	return spannedEntry{
+		CSTEntry: &CSTEntry{
+			text:    builder.src[span.Start.Offset:span.End.Offset],
+			key:     key,
+			isProp:  isProp,
+			value:   value,
+			version: builder.version,
+		},
+		start: span.Start.Offset,
+		end:   span.End.Offset,
+	}
+}
+
+// newCSTNode creates a CSTNode for a node that isn't part of any source yet by
+// formatting it as the given version and parsing it back. Any lines after the
+// first are indented by indent.
+func newCSTNode(node *Node, indent string, version KDLVersion) (*CSTNode, error) {
+	var s strings.Builder
+	p := &printer{w: &s, version: version}
+	err := p.printNode(node, 0)
+	if err != nil {
+		return nil, err
+	}
+	lines := strings.Split(strings.TrimSuffix(s.String(), "\n"), "\n")
+	for i := 1; i < len(lines); i++ {
+		lines[i] = indent + lines[i]
+	}
+
+	doc, err := ParseCSTString(strings.Join(lines, "\n"), WithVersion(version))
+	if err != nil {
+		return nil, err
+	}
+	return doc.nodes[0], nil
+}
+
+func (doc *CSTDocument) GetNodes() []*CSTNode {
+	return doc.nodes
+}
+
+func (doc *CSTDocument) GetVersion() KDLVersion {
+	return doc.version
+}
+
+// AddNode appends a node to the end of the document.
+func (doc *CSTDocument) AddNode(node *Node) error {
+	cstNode, err := newCSTNode(node, "", doc.version)
+	if err != nil {
+		return err
+	}
+	doc.nodes = appendCSTNode(doc.nodes, &doc.trailing, cstNode, "", "", false)
+	return nil
+}
+
+// RemoveNode removes a node from the top level of the document, reporting
+// whether it was found.
+func (doc *CSTDocument) RemoveNode(node *CSTNode) bool {
+	nodes, ok := removeCSTNode(doc.nodes, &doc.trailing, node)
+	doc.nodes = nodes
+	return ok
+}
+
+// RecreateKDL prints the document, reproducing the source it was parsed from
+// apart from the edits made to it.
+func (doc *CSTDocument) RecreateKDL() (string, error) {
+	var s strings.Builder
+	for _, node := range doc.nodes {
+		node.write(&s)
+	}
+	s.WriteString(doc.trailing)
+	return s.String(), nil
+}
+
+// ToDocument parses the document as it currently is into a Document.
+func (doc *CSTDocument) ToDocument() (*Document, error) {
+	s, _ := doc.RecreateKDL()
+	return ParseDocumentString(s, WithVersion(doc.version))
+}
+
+func (node *CSTNode) GetName() string {
+	return node.name
+}
+
+func (node *CSTNode) SetName(name string) {
+	node.name = name
+	node.nameText = (&printer{version: node.version}).key(name)
+}
+
+func (node *CSTNode) GetDeclaredType() string {
+	return node.declaredType
+}
+
+// GetEntries returns the arguments and properties of the node in the order
+// they appear in.
+func (node *CSTNode) GetEntries() []*CSTEntry {
+	return node.entries
+}
+
+func (node *CSTNode) GetChildren() []*CSTNode {
+	if node.children == nil {
+		return nil
+	}
+	return node.children.nodes
+}
+
+// AddArg appends an argument after the last entry of the node.
+func (node *CSTNode) AddArg(value KDLValue) {
+	entry := &CSTEntry{leading: " ", value: value, version: node.version}
+	entry.SetValue(value)
+	node.entries = append(node.entries, entry)
+}
+
+// SetProp changes the value of the property with the given key, or adds it if
+// the node doesn't have it. If the key is repeated, the last occurrence, which
+// is the one that takes effect, is changed.
+func (node *CSTNode) SetProp(key string, value KDLValue) {
+	for i := len(node.entries) - 1; i >= 0; i-- {
+		entry := node.entries[i]
+		if entry.isProp && entry.key == key {
+			entry.SetValue(value)
+			return
+		}
+	}
+
+	entry := &CSTEntry{leading: " ", key: key, isProp: true, version: node.version}
+	entry.SetValue(value)
+	node.entries = append(node.entries, entry)
+}
+
+// RemoveEntry removes an argument or property along with the trivia in front
+// of it, reporting whether it was found.
+func (node *CSTNode) RemoveEntry(entry *CSTEntry) bool {
+	for i, e := range node.entries {
+		if e == entry {
+			node.entries = append(node.entries[:i], node.entries[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// AddChild appends a child to the node, indented like its existing children or
+// one level deeper than the node if it has none.
+func (node *CSTNode) AddChild(child *Node) error {
+	indent := indentation(node.leading)
+	if node.children == nil {
+		node.children = &cstChildren{leading: " "}
+	}
+
+	childIndent := indent + cstIndent
+	if len(node.children.nodes) > 0 {
+		childIndent = indentation(node.children.nodes[len(node.children.nodes)-1].leading)
+	}
+
+	cstNode, err := newCSTNode(child, childIndent, node.version)
+	if err != nil {
+		return err
+	}
+	node.children.nodes = appendCSTNode(node.children.nodes, &node.children.trailing, cstNode, childIndent, indent, true)
+	return nil
+}
+
+// RemoveChild removes one of the node's children, reporting whether it was
+// found. The braces are kept even if it was the last child.
+func (node *CSTNode) RemoveChild(child *CSTNode) bool {
+	if node.children == nil {
+		return false
+	}
+	nodes, ok := removeCSTNode(node.children.nodes, &node.children.trailing, child)
+	node.children.nodes = nodes
+	return ok
+}
+
+// RecreateKDL prints the node and its children without the trivia in front of
+// it.
+func (node *CSTNode) RecreateKDL() (string, error) {
+	var s strings.Builder
+	leading := node.leading
+	node.leading = ""
+	node.write(&s)
+	node.leading = leading
+	return s.String(), nil
+}
+
+func (node *CSTNode) write(s *strings.Builder) {
+	s.WriteString(node.leading + node.prefix + node.nameText)
+	for _, entry := range node.entries {
+		s.WriteString(entry.leading + entry.text)
+	}
+	if node.children != nil {
+		s.WriteString(node.children.leading + "{")
+		for _, child := range node.children.nodes {
+			child.write(s)
+		}
+		s.WriteString(node.children.trailing + "}")
+	}
+	s.WriteString(node.trailing)
+}
+
+// GetKey returns the key of a property, or an empty string for an argument.
+func (entry *CSTEntry) GetKey() string {
+	return entry.key
+}
+
+func (entry *CSTEntry) IsProperty() bool {
+	return entry.isProp
+}
+
+func (entry *CSTEntry) GetValue() KDLValue {
+	return entry.value
+}
+
+// SetValue replaces the value of the entry, keeping the trivia in front of it.
+func (entry *CSTEntry) SetValue(value KDLValue) {
+	entry.value = value
+	p := &printer{version: entry.version}
+	s, _ := p.recreate(value)
+	if entry.isProp {
+		s = p.key(entry.key) + "=" + s
+	}
+	entry.text = s
+}
+
+// appendCSTNode appends node after the last of nodes and any trivia that
+// follows it, on a line of its own. closing is the indentation of the closing
+// brace of a children block, which is kept on its own line as well.
+func appendCSTNode(nodes []*CSTNode, trailing *string, node *CSTNode, indent string, closing string, isBlock bool) []*CSTNode {
+	last := strings.LastIndexAny(*trailing, "\n\r")
+	switch {
+	case last >= 0:
+		node.leading = (*trailing)[:last+1] + indent
+		*trailing = "\n" + (*trailing)[last+1:]
+	case isBlock:
+		node.leading = strings.TrimRightFunc(*trailing, isWhitespace) + "\n" + indent
+		*trailing = "\n" + closing
+	case len(nodes) == 0 && isBlank(*trailing):
+		node.leading = *trailing
+		*trailing = "\n"
+	default:
+		node.leading = *trailing + "\n"
+		*trailing = "\n"
+	}
+	return append(nodes, node)
+}
+
+// removeCSTNode removes node from nodes along with its terminator. The trivia
+// in front of it, like comments on the lines above it or the terminator of
+// the previous node, is kept in front of the node after it, or in trailing if
+// it was the last one.
+func removeCSTNode(nodes []*CSTNode, trailing *string, node *CSTNode) ([]*CSTNode, bool) {
+	for i, n := range nodes {
+		if n != node {
+			continue
+		}
+		head, _, _ := splitTrivia(node.leading)
+		if last := strings.LastIndexAny(node.leading, "\n\r"); last >= 0 {
+			head = node.leading[:last+1]
+		}
+		next := trailing
+		if i+1 < len(nodes) {
+			next = &nodes[i+1].leading
+		}
+		_, rest, _ := splitTrivia(*next)
+		*next = head + rest
+		return append(nodes[:i], nodes[i+1:]...), true
+	}
+	return nodes, false
+}
+
+// splitTrivia splits trivia after its first terminator, reporting whether it
+// had one.
+func splitTrivia(trivia string) (string, string, bool) {
+	inComment := false
+	for i, r := range trivia {
+		end := i + utf8.RuneLen(r)
+		switch {
+		case isNewline(r):
+			if r == '\r' && strings.HasPrefix(trivia[end:], "\n") {
+				end++
+			}
+			return trivia[:end], trivia[end:], true
+		case r == semicolon && !inComment:
+			return trivia[:end], trivia[end:], true
+		case r == slash && strings.HasPrefix(trivia[end:], "/"):
+			inComment = true
+		}
+	}
+	return "", trivia, false
+}
+
+// indentation returns the whitespace at the start of the last line of trivia,
+// which is the indentation of whatever comes after it.
+func indentation(trivia string) string {
+	lines := splitLines(trivia)
+	last := lines[len(lines)-1]
+	if !isBlank(last) {
+		return ""
+	}
+	return last
+}
@@ -0,0 +1,186 @@
+package kdlgo
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCSTRoundTrip(t *testing.T) {
+	files, err := ioutil.ReadDir(complianceInputDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, file := range files {
+		name := file.Name()
+		data, err := ioutil.ReadFile(filepath.Join(complianceInputDir, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		doc, err := ParseCSTString(string(data), WithVersion(KDLVersion1))
+		if err != nil {
+			continue
+		}
+		s, _ := doc.RecreateKDL()
+		if s != string(data) {
+			t.Errorf("%s: Expected:\n%q\nGot:\n%q", name, string(data), s)
+		}
+	}
+
+	inputs := []string{
+		"node 1 /* inline */ key=\"val\" // trailing\r\n",
+		"/- disabled\nnode /- 1 2 { /- child; child }\n\n",
+		"a; b;c\t;\n",
+		"parent {\n    child 1\n}\n// end of document",
+	}
+	for _, input := range inputs {
+		doc, err := ParseCSTString(input)
+		if err != nil {
+			t.Fatal(err)
+		}
+		s, _ := doc.RecreateKDL()
+		if s != input {
+			t.Errorf("Expected:\n%q\nGot:\n%q", input, s)
+		}
+	}
+}
+
+func TestCSTEdit(t *testing.T) {
+	input := strings.Join([]string{
+		"// Settings",
+		"server \"localhost\" port=80 /* default */ {",
+		"    // Keep this around",
+		"    timeout 30",
+		"}",
+		"/- debug true",
+		"",
+	}, "\n")
+	doc, err := ParseCSTString(input)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	server := doc.GetNodes()[0]
+	server.SetProp("port", NewKDLInt("", 8080).GetValue())
+	server.AddArg(NewKDLBool("", true).GetValue())
+	server.GetEntries()[0].SetValue(NewKDLString("", "example.com").GetValue())
+	err = server.AddChild(newTestNode("retries", NewKDLInt("", 3).GetValue()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = doc.AddNode(NewNode("client"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := strings.Join([]string{
+		"// Settings",
+		"server \"example.com\" port=8080 true /* default */ {",
+		"    // Keep this around",
+		"    timeout 30",
+		"    retries 3",
+		"}",
+		"/- debug true",
+		"client",
+		"",
+	}, "\n")
+	s, _ := doc.RecreateKDL()
+	if s != expected {
+		t.Errorf("Expected:\n%s\nGot:\n%s", expected, s)
+	}
+
+	server.RemoveChild(server.GetChildren()[0])
+	server.RemoveEntry(server.GetEntries()[1])
+	doc.RemoveNode(doc.GetNodes()[1])
+	expected = strings.Join([]string{
+		"// Settings",
+		"server \"example.com\" true /* default */ {",
+		"    // Keep this around",
+		"    retries 3",
+		"}",
+		"/- debug true",
+		"",
+	}, "\n")
+	s, _ = doc.RecreateKDL()
+	if s != expected {
+		t.Errorf("Expected:\n%s\nGot:\n%s", expected, s)
+	}
+
+	parsed, err := doc.ToDocument()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(parsed.GetNodes()) != 1 || len(parsed.GetNodes()[0].GetChildren()) != 1 {
+		t.Error("Expected the edited document to parse back")
+	}
+}
+
+func TestCSTEditV2(t *testing.T) {
+	input := strings.Join([]string{
+		"/- kdl-version 2",
+		"server #\"localhost\"# {",
+		"    timeout 30",
+		"}",
+		"",
+	}, "\n")
+	doc, err := ParseCSTString(input, WithVersion(KDLVersionAuto))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	server := doc.GetNodes()[0]
+	server.SetProp("secure", NewKDLBool("", true).GetValue())
+	server.AddArg(NewKDLNull("").GetValue())
+	server.GetEntries()[0].SetValue(NewKDLRawString("", `C:\"srv"`).GetValue())
+	child := newTestNode("retries", NewKDLBool("", false).GetValue())
+	child.AddProp("inf", NewKDLString("", "a\nb").GetValue())
+	err = server.AddChild(child)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = doc.AddNode(newTestNode("client", NewKDLNull("").GetValue()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := strings.Join([]string{
+		"/- kdl-version 2",
+		"server #\"C:\\\"srv\"\"# secure=#true #null {",
+		"    timeout 30",
+		"    retries #false \"inf\"=\"a\\nb\"",
+		"}",
+		"client #null",
+		"",
+	}, "\n")
+	s, _ := doc.RecreateKDL()
+	if s != expected {
+		t.Errorf("Expected:\n%s\nGot:\n%s", expected, s)
+	}
+
+	parsed, err := doc.ToDocument()
+	if err != nil {
+		t.Fatal(err)
+	}
+	kdl, _ := parsed.Format(FormatOptions{})
+	expectedKDL := strings.Join([]string{
+		"server #\"C:\\\"srv\"\"# #null secure=#true {",
+		"    timeout 30",
+		"    retries #false \"inf\"=\"a\\nb\"",
+		"}",
+		"client #null",
+		"",
+	}, "\n")
+	if kdl != expectedKDL {
+		t.Errorf("Expected:\n%s\nGot:\n%s", expectedKDL, kdl)
+	}
+}
+
+func newTestNode(name string, args ...KDLValue) *Node {
+	node := NewNode(name)
+	for _, arg := range args {
+		node.AddArg(arg)
+	}
+	return node
+}
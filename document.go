@@ -0,0 +1,265 @@
+package kdlgo
+
+import (
+	"strings"
+)
+
+type Document struct {
+	nodes   []*Node
+	version KDLVersion
+}
+
+func NewDocument(nodes ...*Node) *Document {
+	return &Document{nodes: nodes}
+}
+
+func (doc *Document) GetNodes() []*Node {
+	return doc.nodes
+}
+
+// GetVersion returns the version of KDL the document was parsed as, or
+// KDLVersionAuto if it wasn't parsed.
+func (doc *Document) GetVersion() KDLVersion {
+	return doc.version
+}
+
+// First returns the first node named name, or nil if there is none.
+func (doc *Document) First(name string) *Node {
+	return firstNode(doc.nodes, name)
+}
+
+// All returns every node named name in the order they appear in.
+func (doc *Document) All(name string) []*Node {
+	return allNodes(doc.nodes, name)
+}
+
+// Has reports whether the document has a node named name.
+func (doc *Document) Has(name string) bool {
+	return firstNode(doc.nodes, name) != nil
+}
+
+func (doc *Document) AddNode(node *Node) {
+	doc.nodes = append(doc.nodes, node)
+}
+
+func (doc *Document) RecreateKDL() (string, error) {
+	var s strings.Builder
+	for _, node := range doc.nodes {
+		str, err := node.RecreateKDL()
+		if err != nil {
+			return "", err
+		}
+		s.WriteString(str + "\n")
+	}
+	return s.String(), nil
+}
+
+type Node struct {
+	name         string
+	args         []KDLValue
+	props        []Property
+	children     []*Node
+	declaredType string
+	span         Span
+	nameSpan     Span
+	typeSpan     Span
+
+	// childrenSpan is where the braces of the children block are, and
+	// trailingEnd where the whitespace and comments after the node end, right
+	// before its terminator.
+	childrenSpan Span
+	trailingEnd  Position
+}
+
+func NewNode(name string) *Node {
+	return &Node{name: name}
+}
+
+func (node *Node) GetName() string {
+	return node.name
+}
+
+// GetDeclaredType returns the type annotation of the node, e.g. "author" for
+// `(author)node`, or an empty string if it has none.
+func (node *Node) GetDeclaredType() string {
+	return node.declaredType
+}
+
+func (node *Node) SetDeclaredType(declaredType string) {
+	node.declaredType = declaredType
+}
+
+// GetSpan returns where the node was read from, starting at its type
+// annotation or name and ending after its last argument, property or
+// children block.
+func (node *Node) GetSpan() Span {
+	return node.span
+}
+
+func (node *Node) GetNameSpan() Span {
+	return node.nameSpan
+}
+
+func (node *Node) GetTypeSpan() Span {
+	return node.typeSpan
+}
+
+// GetArgs returns the node's arguments in the order they were declared.
+func (node *Node) GetArgs() []KDLValue {
+	return node.args
+}
+
+// GetProps returns every property of the node in the order they were
+// declared, including repeated keys.
+func (node *Node) GetProps() []Property {
+	return node.props
+}
+
+// GetProp returns the value of the property named key. If the key is
+// repeated, the rightmost one wins as KDL requires.
+func (node *Node) GetProp(key string) (KDLValue, bool) {
+	for i := len(node.props) - 1; i >= 0; i-- {
+		if node.props[i].key == key {
+			return node.props[i].value, true
+		}
+	}
+	return KDLValue{}, false
+}
+
+// GetPropAll returns the values of every occurrence of the property named key
+// in the order they were declared.
+func (node *Node) GetPropAll(key string) []KDLValue {
+	var values []KDLValue
+	for _, prop := range node.props {
+		if prop.key == key {
+			values = append(values, prop.value)
+		}
+	}
+	return values
+}
+
+// GetUniqueProps returns the properties of the node with only the rightmost
+// occurrence of every key, in the order those were declared.
+func (node *Node) GetUniqueProps() []Property {
+	return dedupeProps(node.props)
+}
+
+func (node *Node) GetChildren() []*Node {
+	return node.children
+}
+
+// First returns the first child named name, or nil if there is none.
+func (node *Node) First(name string) *Node {
+	return firstNode(node.children, name)
+}
+
+// All returns every child named name in the order they appear in.
+func (node *Node) All(name string) []*Node {
+	return allNodes(node.children, name)
+}
+
+// Has reports whether the node has a child named name.
+func (node *Node) Has(name string) bool {
+	return firstNode(node.children, name) != nil
+}
+
+func (node *Node) AddArg(value KDLValue) {
+	node.args = append(node.args, value)
+}
+
+func (node *Node) AddProp(key string, value KDLValue) {
+	node.props = append(node.props, NewProperty(key, value))
+}
+
+func (node *Node) AddChild(child *Node) {
+	node.children = append(node.children, child)
+}
+
+func (node *Node) RecreateKDL() (string, error) {
+	var s strings.Builder
+	if len(node.declaredType) > 0 {
+		s.WriteString("(" + recreateKey(node.declaredType) + ")")
+	}
+	s.WriteString(recreateKey(node.name))
+	for _, arg := range node.args {
+		str, err := arg.RecreateKDL()
+		if err != nil {
+			return "", err
+		}
+		s.WriteString(" " + str)
+	}
+	for _, prop := range node.props {
+		str, err := prop.RecreateKDL()
+		if err != nil {
+			return "", err
+		}
+		s.WriteString(" " + str)
+	}
+	if len(node.children) > 0 {
+		s.WriteString(" { ")
+		for _, child := range node.children {
+			str, err := child.RecreateKDL()
+			if err != nil {
+				return "", err
+			}
+			s.WriteString(str + "; ")
+		}
+		s.WriteString("}")
+	}
+	return s.String(), nil
+}
+
+func firstNode(nodes []*Node, name string) *Node {
+	for _, node := range nodes {
+		if node.name == name {
+			return node
+		}
+	}
+	return nil
+}
+
+func allNodes(nodes []*Node, name string) []*Node {
+	var matches []*Node
+	for _, node := range nodes {
+		if node.name == name {
+			matches = append(matches, node)
+		}
+	}
+	return matches
+}
+
+type Property struct {
+	key     string
+	value   KDLValue
+	span    Span
+	keySpan Span
+}
+
+func NewProperty(key string, value KDLValue) Property {
+	return Property{key: key, value: value}
+}
+
+func (prop Property) GetKey() string {
+	return prop.key
+}
+
+func (prop Property) GetValue() KDLValue {
+	return prop.value
+}
+
+// GetSpan returns where the whole `key=value` pair was read from.
+func (prop Property) GetSpan() Span {
+	return prop.span
+}
+
+func (prop Property) GetKeySpan() Span {
+	return prop.keySpan
+}
+
+func (prop Property) RecreateKDL() (string, error) {
+	s, err := prop.value.RecreateKDL()
+	if err != nil {
+		return "", err
+	}
+	return recreateKey(prop.key) + "=" + s, nil
+}
@@ -0,0 +1,208 @@
+package kdlgo
+
+import (
+	"strconv"
+	"testing"
+)
+
+func TestParseDocument(t *testing.T) {
+	doc, err := ParseDocumentString(`foo bar=true "baz" quux=false 1 2 3
+/- ignored "node"
+parent "arg" {
+    child1 12
+    child2 key="value" /- skipped=true
+}
+"quoted node" r#"raw"#
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	nodes := doc.GetNodes()
+	if len(nodes) != 3 {
+		t.Fatal("There should be 3 nodes. Got " + strconv.Itoa(len(nodes)) + " instead.")
+	}
+
+	foo := nodes[0]
+	if foo.GetName() != "foo" {
+		t.Error("Expected node name 'foo' but got '" + foo.GetName() + "' instead")
+	}
+	if len(foo.GetArgs()) != 4 {
+		t.Error("Expected 4 arguments but got " + strconv.Itoa(len(foo.GetArgs())))
+	}
+	props := foo.GetProps()
+	if len(props) != 2 || props[0].GetKey() != "bar" || props[1].GetKey() != "quux" {
+		t.Error("Properties of 'foo' are incorrectly parsed")
+	}
+	if props[0].GetValue().Type != KDLBoolType || !props[0].GetValue().Bool {
+		t.Error("Expected property 'bar' to be true")
+	}
+	if len(foo.GetChildren()) != 0 {
+		t.Error("Node 'foo' should not have any children")
+	}
+
+	parent := nodes[1]
+	children := parent.GetChildren()
+	if len(children) != 2 {
+		t.Fatal("There should be 2 children. Got " + strconv.Itoa(len(children)) + " instead.")
+	}
+	if len(children[1].GetProps()) != 1 {
+		t.Error("Slashdashed property should not be parsed")
+	}
+
+	expected := []string{
+		`foo "baz" 1 2 3 bar=true quux=false`,
+		`parent "arg" { child1 12; child2 key="value"; }`,
+		`"quoted node" r"raw"`,
+	}
+	for i, node := range nodes {
+		s, err := node.RecreateKDL()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if s != expected[i] {
+			t.Error(
+				"Item number "+strconv.Itoa(i+1)+" is incorrectly parsed.\n",
+				"Expected: '"+expected[i]+"' but got '"+s+"' instead",
+			)
+		}
+	}
+}
+
+func TestParseDocumentInvalid(t *testing.T) {
+	invalid := []string{
+		`node "arg"{}"arg2"`,
+		`node bare`,
+		`node {`,
+		`}`,
+		`true "arg"`,
+		`node "arg""arg2"`,
+	}
+	for _, s := range invalid {
+		_, err := ParseDocumentString(s)
+		if err == nil {
+			t.Error("Expected '" + s + "' to fail to parse")
+		}
+	}
+}
+
+func TestParseTypeAnnotations(t *testing.T) {
+	doc, err := ParseDocumentString(`(author)node (u8)123 (date)"2021-01-01" key=(uuid)"abc"
+("quoted type")other`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	nodes := doc.GetNodes()
+	if nodes[0].GetDeclaredType() != "author" {
+		t.Error("Expected node type 'author' but got '" + nodes[0].GetDeclaredType() + "' instead")
+	}
+	args := nodes[0].GetArgs()
+	if args[0].GetDeclaredType() != "u8" || args[1].GetDeclaredType() != "date" {
+		t.Error("Argument type annotations are incorrectly parsed")
+	}
+	if nodes[0].GetProps()[0].GetValue().GetDeclaredType() != "uuid" {
+		t.Error("Property type annotation is incorrectly parsed")
+	}
+	if nodes[1].GetDeclaredType() != "quoted type" {
+		t.Error("Expected node type 'quoted type' but got '" + nodes[1].GetDeclaredType() + "' instead")
+	}
+
+	s, err := nodes[0].RecreateKDL()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `(author)node (u8)123 (date)"2021-01-01" key=(uuid)"abc"`
+	if s != expected {
+		t.Error("Expected: '" + expected + "' but got '" + s + "' instead")
+	}
+
+	invalid := []string{
+		"node (type)",
+		"(type)",
+		"node key=(type)",
+		"node (type)key=10",
+		"node (type) 10",
+	}
+	for _, s := range invalid {
+		_, err := ParseDocumentString(s)
+		if err == nil {
+			t.Error("Expected '" + s + "' to fail to parse")
+		}
+	}
+}
+
+func TestParseSpans(t *testing.T) {
+	doc, err := ParseDocumentString("// comment\r\n(t)node 12 ключ=(u8)5 {\n\tchild \"é\"\n}\nnext")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	checkSpan := func(name string, span Span, expected Span) {
+		if span != expected {
+			t.Errorf("Expected %s span to be %+v but got %+v instead", name, expected, span)
+		}
+	}
+
+	nodes := doc.GetNodes()
+	node := nodes[0]
+	checkSpan("node", node.GetSpan(), Span{Position{12, 2, 1}, Position{53, 4, 2}})
+	checkSpan("node type", node.GetTypeSpan(), Span{Position{12, 2, 1}, Position{15, 2, 4}})
+	checkSpan("node name", node.GetNameSpan(), Span{Position{15, 2, 4}, Position{19, 2, 8}})
+	checkSpan("argument", node.GetArgs()[0].GetSpan(), Span{Position{20, 2, 9}, Position{22, 2, 11}})
+
+	prop := node.GetProps()[0]
+	checkSpan("property", prop.GetSpan(), Span{Position{23, 2, 12}, Position{37, 2, 22}})
+	checkSpan("property key", prop.GetKeySpan(), Span{Position{23, 2, 12}, Position{31, 2, 16}})
+	checkSpan("property value", prop.GetValue().GetSpan(), Span{Position{32, 2, 17}, Position{37, 2, 22}})
+	checkSpan("property value type", prop.GetValue().GetTypeSpan(), Span{Position{32, 2, 17}, Position{36, 2, 21}})
+
+	child := node.GetChildren()[0]
+	checkSpan("child", child.GetSpan(), Span{Position{41, 3, 2}, Position{51, 3, 11}})
+	checkSpan("next", nodes[1].GetSpan(), Span{Position{54, 5, 1}, Position{58, 5, 5}})
+}
+
+func TestDocumentLookups(t *testing.T) {
+	doc, err := ParseDocumentString(`node 1
+other
+node 2 key=1 other=true key=3 {
+    child "a"
+    child "b"
+}
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !doc.Has("node") || !doc.Has("other") || doc.Has("missing") {
+		t.Error("Has should only find nodes in the document")
+	}
+	if doc.First("missing") != nil || doc.All("missing") != nil {
+		t.Error("Expected no nodes named 'missing'")
+	}
+	nodes := doc.All("node")
+	if len(nodes) != 2 || doc.First("node") != nodes[0] {
+		t.Fatal("Expected both nodes named 'node' in order")
+	}
+
+	node := nodes[1]
+	children := node.All("child")
+	if len(children) != 2 || node.First("child") != children[0] || node.Has("node") {
+		t.Error("Children of the node are incorrectly looked up")
+	}
+
+	value, ok := node.GetProp("key")
+	if !ok || value.NumberString() != "3" {
+		t.Error("Expected the rightmost 'key' to win")
+	}
+	if _, ok := node.GetProp("missing"); ok {
+		t.Error("Expected no property named 'missing'")
+	}
+	if all := node.GetPropAll("key"); len(all) != 2 || all[0].NumberString() != "1" {
+		t.Error("Expected every occurrence of 'key' in order")
+	}
+	props := node.GetUniqueProps()
+	if len(props) != 2 || props[0].GetKey() != "other" || props[1].GetKey() != "key" {
+		t.Error("Expected unique properties in the order they were last declared")
+	}
+}
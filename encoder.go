@@ -0,0 +1,67 @@
+package kdlgo
+
+import (
+	"io"
+)
+
+// Encoder writes KDL to an io.Writer one node at a time, formatted like
+// Document.Format, so that large documents don't have to be built in memory
+// first.
+type Encoder struct {
+	p   *printer
+	err error
+}
+
+func NewEncoder(w io.Writer) *Encoder {
+	return &Encoder{p: &printer{w: w}}
+}
+
+// SetFormatOptions changes how the nodes written from now on are formatted,
+// e.g. their indentation.
+func (enc *Encoder) SetFormatOptions(opts FormatOptions) {
+	enc.p.opts = opts
+}
+
+// EncodeNode writes the node and its children followed by a newline. Once
+// writing fails, the error is returned by every later call as well.
+func (enc *Encoder) EncodeNode(node *Node) error {
+	if enc.err != nil {
+		return enc.err
+	}
+	enc.err = enc.p.printNode(node, 0)
+	return enc.err
+}
+
+// EncodeDocument writes every node of the document.
+func (enc *Encoder) EncodeDocument(doc *Document) error {
+	for _, node := range doc.nodes {
+		err := enc.EncodeNode(node)
+		if err != nil {
+			return err
+		}
+	}
+	return enc.err
+}
+
+// EncodeObject writes a KDLObject as returned by ParseFile and the other
+// legacy parse functions. Objects holding other objects have them written as
+// indented children.
+func (enc *Encoder) EncodeObject(obj KDLObject) error {
+	if enc.err != nil {
+		return enc.err
+	}
+	enc.err = enc.p.printObject(obj, 0)
+	return enc.err
+}
+
+// EncodeObjects writes every object in objs, which is what ParseFile returns
+// for a whole document.
+func (enc *Encoder) EncodeObjects(objs KDLObjects) error {
+	for _, obj := range objs.GetValue().Objects {
+		err := enc.EncodeObject(obj)
+		if err != nil {
+			return err
+		}
+	}
+	return enc.err
+}
@@ -0,0 +1,85 @@
+package kdlgo
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+func TestEncoder(t *testing.T) {
+	doc, err := ParseDocumentString(formatInput)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	enc := NewEncoder(&buf)
+	enc.SetFormatOptions(FormatOptions{Indent: 2})
+	err = enc.EncodeDocument(doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = enc.EncodeNode(NewNode("last"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected, _ := doc.Format(FormatOptions{Indent: 2})
+	expected += "last\n"
+	if buf.String() != expected {
+		t.Errorf("Expected:\n%s\nGot:\n%s", expected, buf.String())
+	}
+}
+
+func TestEncoderObjects(t *testing.T) {
+	objs, err := ParseString("parent { child 1; other \"two\" }\nsibling true null")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	err = NewEncoder(&buf).EncodeObjects(objs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := "parent {\n    child 1\n    other \"two\"\n}\nsibling true null\n"
+	if buf.String() != expected {
+		t.Errorf("Expected:\n%s\nGot:\n%s", expected, buf.String())
+	}
+}
+
+type failingWriter struct {
+	writes int
+}
+
+var errWriteFailed = errors.New("write failed")
+
+func (w *failingWriter) Write(p []byte) (int, error) {
+	w.writes++
+	return 0, errWriteFailed
+}
+
+func TestEncoderErrors(t *testing.T) {
+	w := &failingWriter{}
+	enc := NewEncoder(w)
+	err := enc.EncodeNode(NewNode("node"))
+	if !errors.Is(err, errWriteFailed) {
+		t.Fatalf("Expected the write error, got %v", err)
+	}
+	err = enc.EncodeNode(NewNode("node"))
+	if !errors.Is(err, errWriteFailed) || w.writes != 1 {
+		t.Errorf("Expected the write error to be returned without writing again, got %v after %d writes", err, w.writes)
+	}
+
+	node := NewNode("node")
+	node.AddArg(KDLValue{Type: "unknown"})
+	err = NewEncoder(&bytes.Buffer{}).EncodeNode(node)
+	if err == nil {
+		t.Error("Expected an error for a value of unknown type")
+	}
+
+	_, err = RecreateKDLObj(KDLString{key: "node", value: KDLValue{Type: "unknown"}})
+	if err == nil {
+		t.Error("Expected RecreateKDLObj to return the error")
+	}
+}
@@ -2,69 +2,373 @@ package kdlgo
 
 import (
 	"errors"
+	"io"
+	"reflect"
 	"strconv"
+	"strings"
 )
 
+// KDLErrorType is the kind of an error. It implements error itself so that
+// errors.Is(err, KDLInvalidSyntax) can be used to classify any error returned
+// by this package, including a *ParseError.
 type KDLErrorType string
 
+func (errType KDLErrorType) Error() string {
+	return string(errType)
+}
+
 const (
-	KDLEmptyArray      = "Array is empty"
-	KDLDifferentKey    = "All keys of KDLObject to convert to document should be the same"
-	KDLInvalidKeyChar  = "Invalid character for key"
-	KDLInvalidNumValue = "Invalid numeric value"
-	KDLInvalidSyntax   = "Invalid syntax"
-	KDLInvalidType     = "Invalid KDLType"
-	KDLUnexpectedEOF   = "Unexpected end of file"
+	KDLEmptyArray        KDLErrorType = "Array is empty"
+	KDLDifferentKey      KDLErrorType = "All keys of KDLObject to convert to document should be the same"
+	KDLInvalidKeyChar    KDLErrorType = "Invalid character for key"
+	KDLInvalidNumValue   KDLErrorType = "Invalid numeric value"
+	KDLInvalidSyntax     KDLErrorType = "Invalid syntax"
+	KDLInvalidEscape     KDLErrorType = "Invalid escape sequence"
+	KDLInvalidType       KDLErrorType = "Invalid KDLType"
+	KDLUnexpectedEOF     KDLErrorType = "Unexpected end of file"
+	KDLReadFailure       KDLErrorType = "Failed to read input"
+	KDLUnsupportedType   KDLErrorType = "Unsupported type"
+	KDLInvalidTag        KDLErrorType = "Invalid kdl struct tag"
+	KDLTypeMismatch      KDLErrorType = "Value doesn't match the type it is decoded into"
+	KDLNumberOutOfRange  KDLErrorType = "Number out of range"
+	KDLUnknownField      KDLErrorType = "No field to decode into"
+	KDLMarshalerFailure  KDLErrorType = "Custom marshaler failed"
+	KDLInvalidAnnotation KDLErrorType = "Value doesn't satisfy its type annotation"
+	KDLInvalidGeneric    KDLErrorType = "Not a node converted by ToGeneric"
+	KDLInvalidJiK        KDLErrorType = "Not valid JSON-in-KDL"
+	KDLInvalidXiK        KDLErrorType = "Not valid XML-in-KDL"
+	KDLInvalidJSON       KDLErrorType = "Not a document or node written by MarshalJSON"
+	KDLInvalidQuery      KDLErrorType = "Invalid KDL query"
+	KDLInvalidSchema     KDLErrorType = "Invalid KDL schema"
+	KDLSchemaViolation   KDLErrorType = "Document doesn't satisfy its schema"
+	KDLNonFiniteNumber   KDLErrorType = "KDL 1.0 has no infinite or NaN numbers"
 
 	// These should be caught and handled internally
-	kdlKeyOnly     = "Internal only: Key only"
-	kdlEndOfObj    = "Internal only: End of KDLObject"
-	kdlNothingLeft = "Internal only: Nothing else left to parse"
+	kdlKeyOnly     KDLErrorType = "Internal only: Key only"
+	kdlEndOfObj    KDLErrorType = "Internal only: End of KDLObject"
+	kdlNothingLeft KDLErrorType = "Internal only: Nothing else left to parse"
 )
 
+// ParseError is returned by the parse functions when the input can't be
+// parsed. Line and Column start at 1 and Offset, in bytes, at 0. Snippet is the
+// line of the input the error occurred on.
+type ParseError struct {
+	Kind    KDLErrorType
+	Line    int
+	Column  int
+	Offset  int
+	Snippet string
+
+	// Err is the underlying error when Kind is KDLReadFailure, or why the
+	// query is invalid when it is KDLInvalidQuery.
+	Err error
+}
+
+func (parseErr *ParseError) Error() string {
+	msg := string(parseErr.Kind)
+	if parseErr.Err != nil {
+		msg += ": " + parseErr.Err.Error()
+	}
+	return msg + "\nOn line " + strconv.Itoa(parseErr.Line) +
+		" column " + strconv.Itoa(parseErr.Column)
+}
+
+func (parseErr *ParseError) Is(target error) bool {
+	kind, ok := target.(KDLErrorType)
+	return ok && kind == parseErr.Kind
+}
+
+func (parseErr *ParseError) Unwrap() error {
+	return parseErr.Err
+}
+
+// MarshalError is returned by Marshal when a value can't be encoded. Field is
+// the name of the struct field the value is in, if any.
+type MarshalError struct {
+	Kind  KDLErrorType
+	Type  reflect.Type
+	Field string
+
+	// Err is the error returned by the value's marshaler when Kind is
+	// KDLMarshalerFailure, or why the value isn't supported when it is
+	// KDLUnsupportedType.
+	Err error
+}
+
+func (marshalErr *MarshalError) Error() string {
+	msg := string(marshalErr.Kind)
+	if marshalErr.Type != nil {
+		msg += ": " + marshalErr.Type.String()
+	}
+	if len(marshalErr.Field) > 0 {
+		msg += " in field " + marshalErr.Field
+	}
+	if marshalErr.Err != nil {
+		msg += ": " + marshalErr.Err.Error()
+	}
+	return msg
+}
+
+func (marshalErr *MarshalError) Is(target error) bool {
+	kind, ok := target.(KDLErrorType)
+	return ok && kind == marshalErr.Kind
+}
+
+func (marshalErr *MarshalError) Unwrap() error {
+	return marshalErr.Err
+}
+
+// UnmarshalError is returned by Unmarshal when a value can't be decoded, and
+// by the functions reading nodes as something else, such as JiKToJSON,
+// XiKToXML, UnmarshalJSON and NewSchema, when a node isn't in the shape they
+// expect. Path is the names of the nodes leading to the value separated by
+// " > ", and Line and Column are where the value is in the input.
+type UnmarshalError struct {
+	Kind   KDLErrorType
+	Type   reflect.Type
+	Path   string
+	Line   int
+	Column int
+
+	// Err is the error returned by the value's unmarshaler when Kind is
+	// KDLMarshalerFailure, or by the annotation's converter when it is
+	// KDLInvalidAnnotation.
+	Err error
+}
+
+func (unmarshalErr *UnmarshalError) Error() string {
+	msg := string(unmarshalErr.Kind)
+	if unmarshalErr.Type != nil {
+		msg += ": " + unmarshalErr.Type.String()
+	}
+	if len(unmarshalErr.Path) > 0 {
+		msg += " at " + unmarshalErr.Path
+	}
+	if unmarshalErr.Err != nil {
+		msg += ": " + unmarshalErr.Err.Error()
+	}
+	if unmarshalErr.Line > 0 {
+		msg += "\nOn line " + strconv.Itoa(unmarshalErr.Line) +
+			" column " + strconv.Itoa(unmarshalErr.Column)
+	}
+	return msg
+}
+
+func (unmarshalErr *UnmarshalError) Is(target error) bool {
+	kind, ok := target.(KDLErrorType)
+	return ok && kind == unmarshalErr.Kind
+}
+
+func (unmarshalErr *UnmarshalError) Unwrap() error {
+	return unmarshalErr.Err
+}
+
+// AnnotationError is returned when a value doesn't satisfy its type
+// annotation. Err is the error returned by the annotation's converter.
+type AnnotationError struct {
+	Annotation string
+	Line       int
+	Column     int
+	Err        error
+}
+
+func (annotationErr *AnnotationError) Error() string {
+	msg := string(KDLInvalidAnnotation) + " (" + annotationErr.Annotation + "): " + annotationErr.Err.Error()
+	if annotationErr.Line > 0 {
+		msg += "\nOn line " + strconv.Itoa(annotationErr.Line) +
+			" column " + strconv.Itoa(annotationErr.Column)
+	}
+	return msg
+}
+
+func (annotationErr *AnnotationError) Is(target error) bool {
+	return target == KDLInvalidAnnotation
+}
+
+func (annotationErr *AnnotationError) Unwrap() error {
+	return annotationErr.Err
+}
+
+// SchemaError is a way a document doesn't satisfy a schema. Path is the names
+// of the nodes leading to the violation separated by " > ", and Line and
+// Column are where it is in the document.
+type SchemaError struct {
+	Path   string
+	Line   int
+	Column int
+	Err    error
+}
+
+func (schemaErr *SchemaError) Error() string {
+	msg := string(KDLSchemaViolation)
+	if len(schemaErr.Path) > 0 {
+		msg += " at " + schemaErr.Path
+	}
+	msg += ": " + schemaErr.Err.Error()
+	if schemaErr.Line > 0 {
+		msg += "\nOn line " + strconv.Itoa(schemaErr.Line) +
+			" column " + strconv.Itoa(schemaErr.Column)
+	}
+	return msg
+}
+
+func (schemaErr *SchemaError) Is(target error) bool {
+	return target == KDLSchemaViolation
+}
+
+func (schemaErr *SchemaError) Unwrap() error {
+	return schemaErr.Err
+}
+
+// SchemaErrors is returned by Schema.Validate and holds every violation found
+// in the document, in the order they were found.
+type SchemaErrors []*SchemaError
+
+func (errs SchemaErrors) Error() string {
+	var s strings.Builder
+	for i, err := range errs {
+		if i > 0 {
+			s.WriteString("\n")
+		}
+		s.WriteString(err.Error())
+	}
+	return s.String()
+}
+
+func (errs SchemaErrors) Is(target error) bool {
+	return len(errs) > 0 && target == KDLSchemaViolation
+}
+
+// As sets target to the first of the errors that can be assigned to it, e.g.
+// a *SchemaError.
+func (errs SchemaErrors) As(target interface{}) bool {
+	for _, err := range errs {
+		if errors.As(err, target) {
+			return true
+		}
+	}
+	return false
+}
+
+// ParseErrors is returned when parsing WithRecovery and holds every error found
+// in the document, in the order they were found.
+type ParseErrors []*ParseError
+
+func (errs ParseErrors) Error() string {
+	var s strings.Builder
+	for i, err := range errs {
+		if i > 0 {
+			s.WriteString("\n")
+		}
+		s.WriteString(err.Error())
+	}
+	return s.String()
+}
+
+// Is reports whether any of the errors is of the target kind.
+func (errs ParseErrors) Is(target error) bool {
+	for _, err := range errs {
+		if err.Is(target) {
+			return true
+		}
+	}
+	return false
+}
+
+// As sets target to the first of the errors that can be assigned to it, e.g.
+// a *ParseError.
+func (errs ParseErrors) As(target interface{}) bool {
+	for _, err := range errs {
+		if errors.As(err, target) {
+			return true
+		}
+	}
+	return false
+}
+
 func wrapError(kdlr *kdlReader, err error) error {
-	return errors.New(
-		err.Error() + "\nOn line " + strconv.Itoa(kdlr.line) +
-			" column " + strconv.Itoa(kdlr.pos),
-	)
+	return wrapErrorAt(kdlr, kdlr.position(), err)
+}
+
+// wrapErrorAt is like wrapError, but reports the error at pos instead of at
+// the current position of the reader, e.g. at the start of an invalid token.
+func wrapErrorAt(kdlr *kdlReader, pos Position, err error) error {
+	var parseErr *ParseError
+	if errors.As(err, &parseErr) {
+		return err
+	}
+
+	parseErr = &ParseError{
+		Line:    pos.Line,
+		Column:  pos.Column,
+		Offset:  pos.Offset,
+		Snippet: kdlr.currentLine(),
+	}
+	if errors.Is(err, io.EOF) {
+		parseErr.Kind = KDLUnexpectedEOF
+	} else if !errors.As(err, &parseErr.Kind) {
+		parseErr.Kind = KDLReadFailure
+		parseErr.Err = err
+	}
+	return parseErr
+}
+
+// nodeErr returns an *UnmarshalError for a node that isn't valid in a
+// microsyntax such as JiK.
+func nodeErr(kind KDLErrorType, node *Node, path string, reason string) error {
+	return &UnmarshalError{
+		Kind:   kind,
+		Path:   path,
+		Line:   node.span.Start.Line,
+		Column: node.span.Start.Column,
+		Err:    errors.New(reason),
+	}
 }
 
 func differentKeysErr() error {
-	return errors.New(KDLDifferentKey)
+	return KDLDifferentKey
 }
 
 func emptyArrayErr() error {
-	return errors.New(KDLEmptyArray)
+	return KDLEmptyArray
+}
+
+func invalidEscapeErr() error {
+	return KDLInvalidEscape
 }
 
 func invalidKeyCharErr() error {
-	return errors.New(KDLInvalidKeyChar)
+	return KDLInvalidKeyChar
 }
 
 func invalidNumValueErr() error {
-	return errors.New(KDLInvalidNumValue)
+	return KDLInvalidNumValue
 }
 
 func invalidSyntaxErr() error {
-	return errors.New(KDLInvalidSyntax)
+	return KDLInvalidSyntax
 }
 
 func invalidTypeErr() error {
-	return errors.New(KDLInvalidType)
+	return KDLInvalidType
 }
 
 func keyOnlyErr() error {
-	return errors.New(kdlKeyOnly)
+	return kdlKeyOnly
 }
 
 func endOfObjErr() error {
-	return errors.New(kdlEndOfObj)
+	return kdlEndOfObj
 }
 
 func nothingLeftErr() error {
-	return errors.New(kdlNothingLeft)
+	return kdlNothingLeft
+}
+
+func nonFiniteNumberErr() error {
+	return KDLNonFiniteNumber
 }
 
 func unexpectedEOFErr() error {
-	return errors.New(KDLUnexpectedEOF)
+	return KDLUnexpectedEOF
 }
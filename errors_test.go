@@ -0,0 +1,138 @@
+package kdlgo
+
+import (
+	"bufio"
+	"errors"
+	"strings"
+	"testing"
+)
+
+type failingReader struct{}
+
+func (failingReader) Read([]byte) (int, error) {
+	return 0, errors.New("disk on fire")
+}
+
+func TestParseError(t *testing.T) {
+	_, err := ParseDocumentString("node 1\nother 0x10g10 \"arg\"\n")
+	if err == nil {
+		t.Fatal("Expected parsing to fail")
+	}
+
+	var parseErr *ParseError
+	if !errors.As(err, &parseErr) {
+		t.Fatal("Expected a *ParseError but got: " + err.Error())
+	}
+	if parseErr.Kind != KDLInvalidNumValue || !errors.Is(err, KDLInvalidNumValue) {
+		t.Error("Expected an invalid numeric value error but got: " + string(parseErr.Kind))
+	}
+	if errors.Is(err, KDLInvalidSyntax) {
+		t.Error("Error should not match a different kind")
+	}
+	if parseErr.Line != 2 || parseErr.Column != 7 || parseErr.Offset != 13 {
+		t.Errorf("Error is reported at the wrong position: %+v", parseErr)
+	}
+	if parseErr.Snippet != `other 0x10g10 "arg"` {
+		t.Error("Expected the snippet to be the offending line but got '" + parseErr.Snippet + "' instead")
+	}
+	if !strings.HasSuffix(err.Error(), "On line 2 column 7") {
+		t.Error("Unexpected error message: " + err.Error())
+	}
+}
+
+func TestParseErrorKinds(t *testing.T) {
+	kinds := map[string]KDLErrorType{
+		"node {":          KDLUnexpectedEOF,
+		"node \"unclosed": KDLUnexpectedEOF,
+		"node bare":       KDLInvalidSyntax,
+		"node true=1":     KDLInvalidKeyChar,
+		"node 1.":         KDLInvalidNumValue,
+	}
+	for s, kind := range kinds {
+		_, err := ParseDocumentString(s)
+		if !errors.Is(err, kind) {
+			t.Errorf("Expected '%s' to fail with '%s' but got: %v", s, kind, err)
+		}
+	}
+
+	_, err := ParseString("node 0x")
+	if !errors.Is(err, KDLInvalidNumValue) {
+		t.Errorf("Expected legacy parser errors to be classified too but got: %v", err)
+	}
+
+	_, err = ParseDocumentReader(bufio.NewReader(failingReader{}))
+	if !errors.Is(err, KDLReadFailure) || errors.Unwrap(err) == nil ||
+		errors.Unwrap(err).Error() != "disk on fire" {
+		t.Errorf("Expected the read failure to be wrapped but got: %v", err)
+	}
+}
+
+func TestParseWithRecovery(t *testing.T) {
+	doc, err := ParseDocumentString(`first 1
+second 0x10g10 { child "ignored" }
+third bare; fourth "ok"
+parent {
+    good 1
+    bad "a""b"
+    good 2
+}
+}
+stray x=}
+last "arg"
+unterminated {
+`, WithRecovery())
+	if doc == nil {
+		t.Fatal("Expected a best-effort document")
+	}
+
+	var errs ParseErrors
+	if !errors.As(err, &errs) {
+		t.Fatalf("Expected ParseErrors but got: %v", err)
+	}
+	expected := []struct {
+		kind   KDLErrorType
+		line   int
+		column int
+	}{
+		{KDLInvalidNumValue, 2, 8},
+		{KDLInvalidSyntax, 3, 7},
+		{KDLInvalidSyntax, 6, 12},
+		{KDLInvalidSyntax, 9, 1},
+		{KDLInvalidSyntax, 10, 9},
+		{KDLUnexpectedEOF, 13, 1},
+	}
+	if len(errs) != len(expected) {
+		t.Fatalf("Expected %d errors but got %d: %v", len(expected), len(errs), err)
+	}
+	for i, e := range expected {
+		if errs[i].Kind != e.kind || errs[i].Line != e.line || errs[i].Column != e.column {
+			t.Errorf("Expected error %d to be '%s' on line %d column %d but got: %v",
+				i+1, e.kind, e.line, e.column, errs[i])
+		}
+	}
+	if !errors.Is(err, KDLUnexpectedEOF) {
+		t.Error("ParseErrors should match any of its kinds")
+	}
+	var first *ParseError
+	if !errors.As(err, &first) || first != errs[0] {
+		t.Error("ParseErrors should be usable as its first *ParseError")
+	}
+
+	var names []string
+	for _, node := range doc.GetNodes() {
+		names = append(names, node.GetName())
+	}
+	if strings.Join(names, " ") != "first second third fourth parent stray last unterminated" {
+		t.Error("Unexpected nodes in recovered document: " + strings.Join(names, " "))
+	}
+	parent := doc.GetNodes()[4]
+	if len(parent.GetChildren()) != 3 {
+		t.Errorf("Expected 3 children in recovered node but got %d", len(parent.GetChildren()))
+	}
+
+	_, err = ParseDocumentString("node 1.\nother 2")
+	var parseErr *ParseError
+	if !errors.As(err, &parseErr) {
+		t.Error("Parsing without recovery should return a single *ParseError")
+	}
+}
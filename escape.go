@@ -0,0 +1,148 @@
+package kdlgo
+
+import (
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// escapes maps the character after a backslash to the character it stands
+// for in both versions of KDL.
+var escapes = map[rune]rune{
+	dquote:    dquote,
+	backslash: backslash,
+	'b':       '\b',
+	'f':       '\f',
+	'n':       '\n',
+	'r':       '\r',
+	't':       '\t',
+}
+
+// maxUnicodeEscape is the maximum number of hex digits in a `\u{...}` escape.
+const maxUnicodeEscape = 6
+
+// unescapeString resolves the escapes in the body of a KDL 1.0 quoted string.
+func unescapeString(s string) (string, error) {
+	return decodeEscapes(s, false)
+}
+
+// unescapeStringV2 resolves the escapes in the body of a KDL 2.0 quoted
+// string, which drops `\/` and adds `\s` and escaped whitespace.
+func unescapeStringV2(s string) (string, error) {
+	return decodeEscapes(s, true)
+}
+
+func decodeEscapes(s string, isVersion2 bool) (string, error) {
+	if !strings.ContainsRune(s, backslash) {
+		return s, nil
+	}
+
+	runes := []rune(s)
+	var unescaped strings.Builder
+	for i := 0; i < len(runes); i++ {
+		if runes[i] != backslash {
+			unescaped.WriteRune(runes[i])
+			continue
+		}
+
+		i++
+		if i == len(runes) {
+			return "", invalidEscapeErr()
+		}
+
+		next := runes[i]
+		if r, ok := escapes[next]; ok {
+			unescaped.WriteRune(r)
+			continue
+		}
+
+		switch {
+		case next == 'u':
+			r, length, err := decodeUnicodeEscape(runes[i+1:])
+			if err != nil {
+				return "", err
+			}
+			unescaped.WriteRune(r)
+			i += length
+		case next == slash && !isVersion2:
+			unescaped.WriteRune(slash)
+		case next == 's' && isVersion2:
+			unescaped.WriteRune(space)
+		case (isWhitespace(next) || isNewline(next)) && isVersion2:
+			for i+1 < len(runes) && (isWhitespace(runes[i+1]) || isNewline(runes[i+1])) {
+				i++
+			}
+		default:
+			return "", invalidEscapeErr()
+		}
+	}
+	return unescaped.String(), nil
+}
+
+// decodeUnicodeEscape decodes the `{...}` that follows `\u`, returning the
+// character along with the number of runes the braces spanned.
+func decodeUnicodeEscape(runes []rune) (rune, int, error) {
+	if len(runes) < 3 || runes[0] != openBracket {
+		return 0, 0, invalidEscapeErr()
+	}
+
+	end := 1
+	for end < len(runes) && runes[end] != closeBracket {
+		if end > maxUnicodeEscape || !isDigit(runes[end], 16) {
+			return 0, 0, invalidEscapeErr()
+		}
+		end++
+	}
+	if end == 1 || end == len(runes) {
+		return 0, 0, invalidEscapeErr()
+	}
+
+	code, err := strconv.ParseUint(string(runes[1:end]), 16, 32)
+	if err != nil || code > unicode.MaxRune || (code >= 0xD800 && code <= 0xDFFF) {
+		return 0, 0, invalidEscapeErr()
+	}
+	return rune(code), end + 1, nil
+}
+
+// escapeString escapes s so that it can be used as the body of a quoted
+// string in either version of KDL.
+func escapeString(s string) string {
+	var escaped strings.Builder
+	for _, r := range s {
+		switch r {
+		case dquote:
+			escaped.WriteString(`\"`)
+		case backslash:
+			escaped.WriteString(`\\`)
+		case '\b':
+			escaped.WriteString(`\b`)
+		case '\f':
+			escaped.WriteString(`\f`)
+		case '\n':
+			escaped.WriteString(`\n`)
+		case '\r':
+			escaped.WriteString(`\r`)
+		case '\t':
+			escaped.WriteString(`\t`)
+		default:
+			if needsUnicodeEscape(r) {
+				escaped.WriteString(`\u{` + strconv.FormatInt(int64(r), 16) + "}")
+			} else {
+				escaped.WriteRune(r)
+			}
+		}
+	}
+	return escaped.String()
+}
+
+// needsUnicodeEscape reports whether r is a control character, newline or
+// any other character that KDL doesn't allow to appear literally in a string.
+func needsUnicodeEscape(r rune) bool {
+	switch {
+	case unicode.IsControl(r), isNewline(r), r == '\uFEFF':
+		return true
+	case r >= '\u200E' && r <= '\u200F', r >= '\u202A' && r <= '\u202E', r >= '\u2066' && r <= '\u2069':
+		return true
+	}
+	return false
+}
@@ -0,0 +1,138 @@
+package kdlgo
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestUnescapeString(t *testing.T) {
+	tests := []struct {
+		escaped  string
+		expected string
+	}{
+		{`\"\\\/\b\f\n\r\t`, "\"\\/\b\f\n\r\t"},
+		{`hello\u{0a}world`, "hello\nworld"},
+		{`\u{1F600}`, "\U0001F600"},
+		{`\u{10FFFF}`, "\U0010FFFF"},
+		{"literal\nnewline", "literal\nnewline"},
+	}
+	for _, test := range tests {
+		s, err := unescapeString(test.escaped)
+		if err != nil {
+			t.Errorf("%q: %v", test.escaped, err)
+			continue
+		}
+		if s != test.expected {
+			t.Errorf("%q: expected %q but got %q", test.escaped, test.expected, s)
+		}
+	}
+
+	invalid := []string{`\x41`, `\a`, `\v`, `\101`, `\A`, `\u{}`, `\u{1234567}`, `\u{D800}`, `\u{110000}`, `\u{12`, `\s`, `trailing\`}
+	for _, escaped := range invalid {
+		_, err := unescapeString(escaped)
+		if !errors.Is(err, KDLInvalidEscape) {
+			t.Errorf("Expected %q to be an invalid escape but got %v", escaped, err)
+		}
+	}
+}
+
+func TestUnescapeStringV2(t *testing.T) {
+	s, err := unescapeStringV2("a\\sb\\   \n\t c")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s != "a bc" {
+		t.Errorf("Expected %q but got %q", "a bc", s)
+	}
+
+	_, err = unescapeStringV2(`\/`)
+	if !errors.Is(err, KDLInvalidEscape) {
+		t.Error("Expected \\/ to be invalid in KDL 2.0")
+	}
+}
+
+func TestRecreateString(t *testing.T) {
+	tests := []struct {
+		s        string
+		expected string
+	}{
+		{"\"\\/\b\f\n\r\t", `"\"\\/\b\f\n\r\t"`},
+		{"\x00\x7f\u2028\ufeff", `"\u{0}\u{7f}\u{2028}\u{feff}"`},
+		{"emoji \U0001F600", "\"emoji \U0001F600\""},
+	}
+	for _, test := range tests {
+		s := RecreateString(test.s)
+		if s != test.expected {
+			t.Errorf("Expected %s but got %s", test.expected, s)
+		}
+		unescaped, err := unescapeString(s[1 : len(s)-1])
+		if err != nil || unescaped != test.s {
+			t.Errorf("%s doesn't round trip: %q, %v", s, unescaped, err)
+		}
+	}
+}
+
+func TestRecreateRawString(t *testing.T) {
+	tests := []struct {
+		s        string
+		expected string
+	}{
+		{`C:\path`, `r"C:\path"`},
+		{`say "hi"`, `r#"say "hi""#`},
+		{`"#`, `r##""#"##`},
+		{``, `r""`},
+	}
+	for _, test := range tests {
+		s := RecreateRawString(test.s)
+		if s != test.expected {
+			t.Errorf("Expected %s but got %s", test.expected, s)
+		}
+		doc, err := ParseDocumentString("node " + s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		value := doc.GetNodes()[0].GetArgs()[0]
+		if value.RawString != test.s {
+			t.Errorf("%s doesn't round trip: %q", s, value.RawString)
+		}
+	}
+}
+
+func TestRecreateKey(t *testing.T) {
+	tests := []struct {
+		key      string
+		expected string
+	}{
+		{"node", "node"},
+		{"kebab-case.name", "kebab-case.name"},
+		{"-", "-"},
+		{"", `""`},
+		{"0node", `"0node"`},
+		{"-1", `"-1"`},
+		{"true", `"true"`},
+		{"null", `"null"`},
+		{"a=b", `"a=b"`},
+		{"(type)", `"(type)"`},
+		{"a{", `"a{"`},
+		{"two words", `"two words"`},
+		{"r#raw", `"r#raw"`},
+		{"line\nbreak", `"line\nbreak"`},
+	}
+	for _, test := range tests {
+		s := recreateKey(test.key)
+		if s != test.expected {
+			t.Errorf("Expected %s but got %s", test.expected, s)
+		}
+	}
+}
+
+func TestParseInvalidEscape(t *testing.T) {
+	_, err := ParseDocumentString(`node "\x41"`)
+	if !errors.Is(err, KDLInvalidEscape) {
+		t.Error("Expected an invalid escape error but got", err)
+	}
+	_, err = ParseString(`node "\x41"`)
+	if !errors.Is(err, KDLInvalidEscape) {
+		t.Error("Expected an invalid escape error but got", err)
+	}
+}
@@ -2,6 +2,7 @@ package kdlgo
 
 import (
 	"bufio"
+	"io/ioutil"
 	"os"
 	"strings"
 )
@@ -44,3 +45,69 @@ func ConvertToDocument(objs []KDLObject) (KDLDocument, error) {
 	}
 	return NewKDLDocument(key, vals), nil
 }
+
+func ParseDocumentFile(fullfilepath string, opts ...ParseOption) (*Document, error) {
+	f, err := os.Open(fullfilepath)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return ParseDocumentReader(bufio.NewReader(f), opts...)
+}
+
+func ParseDocumentString(toParse string, opts ...ParseOption) (*Document, error) {
+	return ParseDocumentReader(bufio.NewReader(strings.NewReader(toParse)), opts...)
+}
+
+func ParseDocumentReader(reader *bufio.Reader, opts ...ParseOption) (*Document, error) {
+	options := newParseOptions(opts)
+	if options.version == KDLVersionAuto {
+		return parseAnyVersion(reader, options)
+	}
+	r := newKDLReader(reader)
+	r.options = options
+	return parseDocument(r)
+}
+
+func ParseCSTFile(fullfilepath string, opts ...ParseOption) (*CSTDocument, error) {
+	f, err := os.Open(fullfilepath)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return ParseCSTReader(bufio.NewReader(f), opts...)
+}
+
+func ParseCSTString(toParse string, opts ...ParseOption) (*CSTDocument, error) {
+	return ParseCSTReader(bufio.NewReader(strings.NewReader(toParse)), opts...)
+}
+
+func ParseCSTReader(reader *bufio.Reader, opts ...ParseOption) (*CSTDocument, error) {
+	data, err := ioutil.ReadAll(reader)
+	if err != nil {
+		return nil, err
+	}
+	doc, err := ParseDocumentString(string(data), opts...)
+	if err != nil {
+		return nil, err
+	}
+	return newCSTDocument(string(data), doc), nil
+}
+
+// ParseSchemaFile parses a KDL Schema Language document and compiles it with
+// NewSchema.
+func ParseSchemaFile(fullfilepath string, opts ...ParseOption) (*Schema, error) {
+	doc, err := ParseDocumentFile(fullfilepath, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return NewSchema(doc)
+}
+
+func ParseSchemaString(toParse string, opts ...ParseOption) (*Schema, error) {
+	doc, err := ParseDocumentString(toParse, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return NewSchema(doc)
+}
@@ -0,0 +1,159 @@
+package kdlgo
+
+import (
+	"reflect"
+	"sort"
+)
+
+// The keys of the maps nodes are converted into by ToGeneric.
+const (
+	GenericName     = "name"
+	GenericType     = "type"
+	GenericArgs     = "args"
+	GenericProps    = "props"
+	GenericChildren = "children"
+)
+
+// ToGeneric converts the document into plain Go values, with every node
+// converted by Node.ToGeneric in the order they appear in. Duplicate node
+// names are all kept.
+func (doc *Document) ToGeneric() []interface{} {
+	return nodesToGeneric(doc.nodes)
+}
+
+// ToGeneric converts the node into a map with the keys:
+//
+//	"name"      the name of the node
+//	"type"      its type annotation, only if it has one
+//	"args"      a []interface{} of its arguments, only if it has any
+//	"props"     a map[string]interface{} of its properties, only if it has
+//	            any, holding the last value of any repeated property
+//	"children"  a []interface{} of its children, only if it has any
+//
+// Values are converted into a bool, string, nil, int64, *big.Int for integers
+// that don't fit in one, or float64. Their type annotations are dropped.
+func (node *Node) ToGeneric() map[string]interface{} {
+	generic := map[string]interface{}{GenericName: node.name}
+	if len(node.declaredType) > 0 {
+		generic[GenericType] = node.declaredType
+	}
+	if len(node.args) > 0 {
+		args := make([]interface{}, len(node.args))
+		for i, arg := range node.args {
+			args[i] = genericValue(arg)
+		}
+		generic[GenericArgs] = args
+	}
+	if len(node.props) > 0 {
+		props := map[string]interface{}{}
+		for _, prop := range node.props {
+			props[prop.key] = genericValue(prop.value)
+		}
+		generic[GenericProps] = props
+	}
+	if len(node.children) > 0 {
+		generic[GenericChildren] = nodesToGeneric(node.children)
+	}
+	return generic
+}
+
+func nodesToGeneric(nodes []*Node) []interface{} {
+	generic := make([]interface{}, len(nodes))
+	for i, node := range nodes {
+		generic[i] = node.ToGeneric()
+	}
+	return generic
+}
+
+// NewDocumentFromGeneric is the reverse of Document.ToGeneric. Properties are
+// added in the order of their keys.
+func NewDocumentFromGeneric(generic []interface{}) (*Document, error) {
+	nodes, err := nodesFromGeneric(generic)
+	if err != nil {
+		return nil, err
+	}
+	return NewDocument(nodes...), nil
+}
+
+// NewNodeFromGeneric is the reverse of Node.ToGeneric. Values can be of any
+// type Marshal accepts for arguments.
+func NewNodeFromGeneric(generic map[string]interface{}) (*Node, error) {
+	name, ok := generic[GenericName].(string)
+	if !ok {
+		return nil, genericErr(generic)
+	}
+	node := NewNode(name)
+
+	if declaredType, ok := generic[GenericType]; ok {
+		node.declaredType, ok = declaredType.(string)
+		if !ok {
+			return nil, genericErr(generic)
+		}
+	}
+
+	if args, ok := generic[GenericArgs]; ok {
+		list, ok := args.([]interface{})
+		if !ok {
+			return nil, genericErr(generic)
+		}
+		for _, arg := range list {
+			value, err := marshalValue(reflect.ValueOf(arg))
+			if err != nil {
+				return nil, err
+			}
+			node.AddArg(value)
+		}
+	}
+
+	if props, ok := generic[GenericProps]; ok {
+		propMap, ok := props.(map[string]interface{})
+		if !ok {
+			return nil, genericErr(generic)
+		}
+		keys := make([]string, 0, len(propMap))
+		for key := range propMap {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			value, err := marshalValue(reflect.ValueOf(propMap[key]))
+			if err != nil {
+				return nil, err
+			}
+			node.AddProp(key, value)
+		}
+	}
+
+	if children, ok := generic[GenericChildren]; ok {
+		list, ok := children.([]interface{})
+		if !ok {
+			return nil, genericErr(generic)
+		}
+		nodes, err := nodesFromGeneric(list)
+		if err != nil {
+			return nil, err
+		}
+		node.children = nodes
+	}
+	return node, nil
+}
+
+func nodesFromGeneric(generic []interface{}) ([]*Node, error) {
+	nodes := make([]*Node, len(generic))
+	for i, g := range generic {
+		m, ok := g.(map[string]interface{})
+		if !ok {
+			return nil, genericErr(g)
+		}
+		node, err := NewNodeFromGeneric(m)
+		if err != nil {
+			return nil, err
+		}
+		nodes[i] = node
+	}
+	return nodes, nil
+}
+
+func genericErr(generic interface{}) error {
+	return &MarshalError{Kind: KDLInvalidGeneric, Type: reflect.TypeOf(generic)}
+}
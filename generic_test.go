@@ -0,0 +1,95 @@
+package kdlgo
+
+import (
+	"math/big"
+	"reflect"
+	"testing"
+)
+
+const genericInput = `package "kdlgo" version=1 version=2
+(dep)dependency "a" optional=true
+dependency "b" {
+    feature "x"
+    feature 18446744073709551616 1.5 null
+}
+`
+
+func TestToGeneric(t *testing.T) {
+	doc, err := ParseDocumentString(genericInput)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	huge, _ := new(big.Int).SetString("18446744073709551616", 10)
+	expected := []interface{}{
+		map[string]interface{}{
+			"name":  "package",
+			"args":  []interface{}{"kdlgo"},
+			"props": map[string]interface{}{"version": int64(2)},
+		},
+		map[string]interface{}{
+			"name":  "dependency",
+			"type":  "dep",
+			"args":  []interface{}{"a"},
+			"props": map[string]interface{}{"optional": true},
+		},
+		map[string]interface{}{
+			"name": "dependency",
+			"args": []interface{}{"b"},
+			"children": []interface{}{
+				map[string]interface{}{"name": "feature", "args": []interface{}{"x"}},
+				map[string]interface{}{"name": "feature", "args": []interface{}{huge, 1.5, nil}},
+			},
+		},
+	}
+	generic := doc.ToGeneric()
+	if !reflect.DeepEqual(generic, expected) {
+		t.Errorf("Expected:\n%#v\nGot:\n%#v", expected, generic)
+	}
+
+	var v interface{}
+	err = Unmarshal([]byte(genericInput), &v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(v, expected) {
+		t.Errorf("Expected Unmarshal into an interface to match ToGeneric, got:\n%#v", v)
+	}
+}
+
+func TestNewDocumentFromGeneric(t *testing.T) {
+	doc, err := ParseDocumentString(genericInput)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rebuilt, err := NewDocumentFromGeneric(doc.ToGeneric())
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := rebuilt.Format(FormatOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `package "kdlgo" version=2
+(dep)dependency "a" optional=true
+dependency "b" {
+    feature "x"
+    feature 18446744073709551616 1.5 null
+}
+`
+	if s != expected {
+		t.Errorf("Expected:\n%s\nGot:\n%s", expected, s)
+	}
+
+	invalid := [][]interface{}{
+		{"not a node"},
+		{map[string]interface{}{"args": []interface{}{1}}},
+		{map[string]interface{}{"name": "node", "props": []interface{}{}}},
+		{map[string]interface{}{"name": "node", "args": []interface{}{struct{}{}}}},
+	}
+	for _, generic := range invalid {
+		if _, err := NewDocumentFromGeneric(generic); err == nil {
+			t.Errorf("Expected an error for %#v", generic)
+		}
+	}
+}
@@ -0,0 +1,298 @@
+package kdlgo
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"math/big"
+	"strings"
+)
+
+// JiKArray and JiKObject are the type annotations JSON-in-KDL uses for
+// arrays and objects that would otherwise be read as something else.
+const (
+	JiKArray  = "array"
+	JiKObject = "object"
+)
+
+var errJiKTrailingData = errors.New("invalid data after top-level JSON value")
+
+// JSONToJiK converts a JSON value into a document holding a single node named
+// "-", following the JSON-in-KDL (JiK) microsyntax:
+//
+//	1                   - 1
+//	[1, 2]              - 1 2
+//	[{"a": 1}]          - { - a=1; }
+//	{"a": 1, "b": [2]}  - a=1 { b 2; }
+//	[] and {}           (array)- and (object)-
+//
+// Numbers are kept exactly as written and object keys in the order they
+// appear in.
+func JSONToJiK(data []byte) (*Document, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	node, err := jikNode(dec, "-")
+	if err != nil {
+		return nil, err
+	}
+	_, err = dec.Token()
+	if err != io.EOF {
+		if err == nil {
+			err = errJiKTrailingData
+		}
+		return nil, err
+	}
+	return NewDocument(node), nil
+}
+
+// jikNode reads the next JSON value from dec into a node named name.
+func jikNode(dec *json.Decoder, name string) (*Node, error) {
+	token, err := dec.Token()
+	if err != nil {
+		return nil, err
+	}
+
+	node := NewNode(name)
+	switch token {
+	case json.Delim('['):
+		return node, jikArray(dec, node)
+	case json.Delim('{'):
+		return node, jikObject(dec, node)
+	}
+	value, err := jsonLiteral(token)
+	if err != nil {
+		return nil, err
+	}
+	node.AddArg(value)
+	return node, nil
+}
+
+// jikArray reads the elements of an array into node, as arguments if they are
+// all literals and as children otherwise.
+func jikArray(dec *json.Decoder, node *Node) error {
+	var elements []*Node
+	isLiterals := true
+	for dec.More() {
+		element, err := jikNode(dec, "-")
+		if err != nil {
+			return err
+		}
+		isLiterals = isLiterals && isJiKLiteral(element)
+		elements = append(elements, element)
+	}
+	if _, err := dec.Token(); err != nil {
+		return err
+	}
+
+	if !isLiterals {
+		node.children = elements
+		return nil
+	}
+	for _, element := range elements {
+		node.args = append(node.args, element.args[0])
+	}
+	if len(node.args) < 2 {
+		node.declaredType = JiKArray
+	}
+	return nil
+}
+
+// jikObject reads the members of an object into node, as properties up to
+// the first one that isn't a literal and as children from there on so that
+// their order is kept.
+func jikObject(dec *json.Decoder, node *Node) error {
+	for dec.More() {
+		token, err := dec.Token()
+		if err != nil {
+			return err
+		}
+		key, _ := token.(string)
+		member, err := jikNode(dec, key)
+		if err != nil {
+			return err
+		}
+		if len(node.children) == 0 && isJiKLiteral(member) {
+			node.AddProp(key, member.args[0])
+		} else {
+			node.AddChild(member)
+		}
+	}
+	if _, err := dec.Token(); err != nil {
+		return err
+	}
+
+	if len(node.props) == 0 && isJiKArrayChildren(node.children) {
+		node.declaredType = JiKObject
+	}
+	return nil
+}
+
+func jsonLiteral(token json.Token) (KDLValue, error) {
+	switch t := token.(type) {
+	case nil:
+		return KDLValue{Type: KDLNullType}, nil
+	case bool:
+		return KDLValue{Bool: t, Type: KDLBoolType}, nil
+	case string:
+		return KDLValue{String: t, Type: KDLStringType}, nil
+	case json.Number:
+		if strings.ContainsAny(string(t), ".eE") {
+			dec, err := parseDecimal(string(t))
+			if err != nil {
+				return KDLValue{}, err
+			}
+			return newDecimalValue(dec), nil
+		}
+		integer, ok := new(big.Int).SetString(string(t), 10)
+		if !ok {
+			return KDLValue{}, invalidNumValueErr()
+		}
+		return newIntegerValue(integer), nil
+	}
+	return KDLValue{}, invalidSyntaxErr()
+}
+
+func isJiKLiteral(node *Node) bool {
+	return len(node.declaredType) == 0 && len(node.args) == 1 &&
+		len(node.props) == 0 && len(node.children) == 0
+}
+
+func isJiKArrayChildren(children []*Node) bool {
+	for _, child := range children {
+		if child.name != "-" {
+			return false
+		}
+	}
+	return true
+}
+
+// JiKToJSON converts a document holding a single JSON-in-KDL node back into
+// JSON. Type annotations other than (array) and (object) are ignored. The
+// error is an *UnmarshalError naming the node if it isn't valid JiK.
+func JiKToJSON(doc *Document) ([]byte, error) {
+	if len(doc.nodes) != 1 {
+		return nil, &UnmarshalError{
+			Kind: KDLInvalidJiK,
+			Err:  errors.New("the document should have exactly one node"),
+		}
+	}
+	var buf bytes.Buffer
+	err := writeJiK(&buf, doc.nodes[0], doc.nodes[0].name)
+	if err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func writeJiK(buf *bytes.Buffer, node *Node, path string) error {
+	switch {
+	case node.declaredType == JiKArray:
+		if len(node.props) > 0 {
+			return nodeErr(KDLInvalidJiK, node, path, "an array can't have properties")
+		}
+		return writeJiKArray(buf, node, path)
+	case node.declaredType == JiKObject:
+		if len(node.args) > 0 {
+			return nodeErr(KDLInvalidJiK, node, path, "an object can't have arguments")
+		}
+		return writeJiKObject(buf, node, path)
+	case len(node.props) == 0 && len(node.children) > 0 && isJiKArrayChildren(node.children):
+		return writeJiKArray(buf, node, path)
+	case len(node.props) > 0 || len(node.children) > 0:
+		if len(node.args) > 0 {
+			return nodeErr(KDLInvalidJiK, node, path, "an object can't have arguments")
+		}
+		return writeJiKObject(buf, node, path)
+	case len(node.args) == 1:
+		return writeJiKValue(buf, node.args[0], node, path)
+	case len(node.args) > 1:
+		return writeJiKArray(buf, node, path)
+	}
+	return nodeErr(KDLInvalidJiK, node, path, "the node has no value")
+}
+
+func writeJiKArray(buf *bytes.Buffer, node *Node, path string) error {
+	buf.WriteByte('[')
+	for i, arg := range node.args {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		err := writeJiKValue(buf, arg, node, path)
+		if err != nil {
+			return err
+		}
+	}
+	for i, child := range node.children {
+		if i > 0 || len(node.args) > 0 {
+			buf.WriteByte(',')
+		}
+		err := writeJiK(buf, child, childPath(path, child.name))
+		if err != nil {
+			return err
+		}
+	}
+	buf.WriteByte(']')
+	return nil
+}
+
+func writeJiKObject(buf *bytes.Buffer, node *Node, path string) error {
+	buf.WriteByte('{')
+	props := dedupeProps(node.props)
+	for i, prop := range props {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		writeJSONString(buf, prop.key)
+		buf.WriteByte(':')
+		err := writeJiKValue(buf, prop.value, node, path)
+		if err != nil {
+			return err
+		}
+	}
+	for i, child := range node.children {
+		if i > 0 || len(props) > 0 {
+			buf.WriteByte(',')
+		}
+		writeJSONString(buf, child.name)
+		buf.WriteByte(':')
+		err := writeJiK(buf, child, childPath(path, child.name))
+		if err != nil {
+			return err
+		}
+	}
+	buf.WriteByte('}')
+	return nil
+}
+
+func writeJiKValue(buf *bytes.Buffer, value KDLValue, node *Node, path string) error {
+	switch value.Type {
+	case KDLBoolType:
+		if value.Bool {
+			buf.WriteString("true")
+		} else {
+			buf.WriteString("false")
+		}
+	case KDLNullType:
+		buf.WriteString("null")
+	case KDLStringType, KDLRawStringType:
+		s, _ := value.ToString()
+		writeJSONString(buf, s)
+	case KDLNumberType:
+		if value.nan || value.Number.IsInf() {
+			return nodeErr(KDLInvalidJiK, node, path, "JSON has no infinite or NaN numbers")
+		}
+		buf.WriteString(value.NumberString())
+	default:
+		return nodeErr(KDLInvalidJiK, node, path, "JSON has no "+string(value.Type)+" values")
+	}
+	return nil
+}
+
+// writeJSONString writes s as a JSON string without escaping HTML characters.
+func writeJSONString(buf *bytes.Buffer, s string) {
+	enc := json.NewEncoder(buf)
+	enc.SetEscapeHTML(false)
+	_ = enc.Encode(s)
+	buf.Truncate(buf.Len() - 1)
+}
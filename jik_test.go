@@ -0,0 +1,102 @@
+package kdlgo
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+func TestJSONToJiK(t *testing.T) {
+	tests := []struct {
+		json     string
+		expected string
+	}{
+		{`1`, "- 1\n"},
+		{`"a<b"`, "- \"a<b\"\n"},
+		{`null`, "- null\n"},
+		{`[]`, "(array)-\n"},
+		{`[true]`, "(array)- true\n"},
+		{`[1, 2.50, 1E+400]`, "- 1 2.50 1E+400\n"},
+		{`{}`, "(object)-\n"},
+		{`{"a": 1, "b": [2, 3], "c": 4}`, "- a=1 {\n    b 2 3\n    c 4\n}\n"},
+		{`{"-": 1}`, "- -=1\n"},
+		{`{"-": [1, 2]}`, "(object)- {\n    - 1 2\n}\n"},
+		{`[{"a": 18446744073709551616}, [], 1]`, "- {\n    - a=18446744073709551616\n    (array)-\n    - 1\n}\n"},
+	}
+	for _, test := range tests {
+		doc, err := JSONToJiK([]byte(test.json))
+		if err != nil {
+			t.Fatal(err)
+		}
+		s, err := doc.Format(FormatOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if s != test.expected {
+			t.Errorf("Expected %s to convert to:\n%s\nGot:\n%s", test.json, test.expected, s)
+		}
+
+		reparsed, err := ParseDocumentString(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		converted, err := JiKToJSON(reparsed)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if compact := compactJSON(t, test.json); string(converted) != compact {
+			t.Errorf("Expected %s to convert back to %s, got %s", s, compact, converted)
+		}
+	}
+
+	for _, invalid := range []string{``, `[1,`, `{"a" 1}`, `1 2`} {
+		if _, err := JSONToJiK([]byte(invalid)); err == nil {
+			t.Errorf("Expected an error for %q", invalid)
+		}
+	}
+}
+
+func TestJiKToJSON(t *testing.T) {
+	tests := []struct {
+		kdl      string
+		expected string
+	}{
+		{`- r"raw" 0x10 (u8)1 { - { - 2; }; }`, `["raw",16,1,[2]]`},
+		{`root a=1 a=2 { b null; - 3; }`, `{"a":2,"b":null,"-":3}`},
+		{`(array)- { a 1; }`, `[1]`},
+	}
+	for _, test := range tests {
+		doc, err := ParseDocumentString(test.kdl)
+		if err != nil {
+			t.Fatal(err)
+		}
+		converted, err := JiKToJSON(doc)
+		if err != nil {
+			t.Errorf("%s: %s", test.kdl, err)
+			continue
+		}
+		if string(converted) != test.expected {
+			t.Errorf("Expected %s to convert to %s, got %s", test.kdl, test.expected, converted)
+		}
+	}
+
+	for _, invalid := range []string{``, "- 1\n- 2", `- 1 { a 2; }`, `(array)- a=1`, `(object)- 1`, `- { - 1 a=2 { b; }; }`} {
+		doc, err := ParseDocumentString(invalid)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := JiKToJSON(doc); !errors.Is(err, KDLInvalidJiK) {
+			t.Errorf("Expected %q to be invalid JiK, got %v", invalid, err)
+		}
+	}
+}
+
+func compactJSON(t *testing.T, s string) string {
+	var buf bytes.Buffer
+	err := json.Compact(&buf, []byte(s))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return buf.String()
+}
@@ -0,0 +1,376 @@
+package kdlgo
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+)
+
+// The keys of the objects values are written as in JSON when they can't be
+// written as a plain JSON literal.
+const (
+	JSONValueType   = "type"
+	JSONValue       = "value"
+	JSONValueNumber = "number"
+)
+
+// MarshalJSON writes the document as a JSON array of its nodes, each written
+// as by Node.MarshalJSON.
+func (doc *Document) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	err := writeNodesJSON(&buf, doc.nodes)
+	if err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// UnmarshalJSON is the reverse of MarshalJSON.
+func (doc *Document) UnmarshalJSON(data []byte) error {
+	dec := newJSONDecoder(data)
+	nodes, err := readNodesJSON(dec, "")
+	if err != nil {
+		return err
+	}
+	*doc = Document{nodes: nodes}
+	return nil
+}
+
+// MarshalJSON writes the node as a JSON object with the same keys as
+// ToGeneric:
+//
+//	{"name": "node", "type": "annotation", "args": [...], "props": {...},
+//	 "children": [...]}
+//
+// Properties are written in the order they were declared, except that only
+// the last of a repeated property, which is the one that takes effect, is
+// written, as JSON objects can't repeat keys. Arguments and properties are written as JSON literals, with
+// numbers written exactly, unless they have a type annotation or are
+// infinite or NaN:
+//
+//	(u8)1   {"type": "u8", "value": 1}
+//	#inf    {"number": "#inf"}
+func (node *Node) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	err := writeNodeJSON(&buf, node)
+	if err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// UnmarshalJSON is the reverse of MarshalJSON.
+func (node *Node) UnmarshalJSON(data []byte) error {
+	dec := newJSONDecoder(data)
+	parsed, err := readNodeJSON(dec, "")
+	if err != nil {
+		return err
+	}
+	*node = *parsed
+	return nil
+}
+
+func writeNodesJSON(buf *bytes.Buffer, nodes []*Node) error {
+	buf.WriteByte('[')
+	for i, node := range nodes {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		err := writeNodeJSON(buf, node)
+		if err != nil {
+			return err
+		}
+	}
+	buf.WriteByte(']')
+	return nil
+}
+
+func writeNodeJSON(buf *bytes.Buffer, node *Node) error {
+	buf.WriteString(`{"` + GenericName + `":`)
+	writeJSONString(buf, node.name)
+	if len(node.declaredType) > 0 {
+		buf.WriteString(`,"` + GenericType + `":`)
+		writeJSONString(buf, node.declaredType)
+	}
+
+	if len(node.args) > 0 {
+		buf.WriteString(`,"` + GenericArgs + `":[`)
+		for i, arg := range node.args {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			err := writeValueJSON(buf, arg)
+			if err != nil {
+				return err
+			}
+		}
+		buf.WriteByte(']')
+	}
+
+	if len(node.props) > 0 {
+		buf.WriteString(`,"` + GenericProps + `":{`)
+		for i, prop := range dedupeProps(node.props) {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			writeJSONString(buf, prop.key)
+			buf.WriteByte(':')
+			err := writeValueJSON(buf, prop.value)
+			if err != nil {
+				return err
+			}
+		}
+		buf.WriteByte('}')
+	}
+
+	if len(node.children) > 0 {
+		buf.WriteString(`,"` + GenericChildren + `":`)
+		err := writeNodesJSON(buf, node.children)
+		if err != nil {
+			return err
+		}
+	}
+	buf.WriteByte('}')
+	return nil
+}
+
+func writeValueJSON(buf *bytes.Buffer, value KDLValue) error {
+	isNonFinite := value.Type == KDLNumberType && (value.nan || value.Number.IsInf())
+	if len(value.declaredType) == 0 && !isNonFinite {
+		return writeLiteralJSON(buf, value)
+	}
+
+	buf.WriteByte('{')
+	if len(value.declaredType) > 0 {
+		buf.WriteString(`"` + JSONValueType + `":`)
+		writeJSONString(buf, value.declaredType)
+		buf.WriteByte(',')
+	}
+	if isNonFinite {
+		buf.WriteString(`"` + JSONValueNumber + `":`)
+		writeJSONString(buf, value.NumberString())
+	} else {
+		buf.WriteString(`"` + JSONValue + `":`)
+		err := writeLiteralJSON(buf, value)
+		if err != nil {
+			return err
+		}
+	}
+	buf.WriteByte('}')
+	return nil
+}
+
+func writeLiteralJSON(buf *bytes.Buffer, value KDLValue) error {
+	switch value.Type {
+	case KDLBoolType:
+		if value.Bool {
+			buf.WriteString("true")
+		} else {
+			buf.WriteString("false")
+		}
+	case KDLNullType:
+		buf.WriteString("null")
+	case KDLStringType, KDLRawStringType:
+		s, _ := value.ToString()
+		writeJSONString(buf, s)
+	case KDLNumberType:
+		buf.WriteString(value.NumberString())
+	default:
+		return invalidTypeErr()
+	}
+	return nil
+}
+
+func newJSONDecoder(data []byte) *json.Decoder {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	return dec
+}
+
+func readNodesJSON(dec *json.Decoder, path string) ([]*Node, error) {
+	err := readJSONDelim(dec, '[', path)
+	if err != nil {
+		return nil, err
+	}
+	var nodes []*Node
+	for dec.More() {
+		node, err := readNodeJSON(dec, path)
+		if err != nil {
+			return nil, err
+		}
+		nodes = append(nodes, node)
+	}
+	return nodes, readJSONDelim(dec, ']', path)
+}
+
+// readNodeJSON reads a node inside the node at path. Its members are read
+// before any of them is decoded so that errors name the node even if "name"
+// isn't its first key.
+func readNodeJSON(dec *json.Decoder, path string) (*Node, error) {
+	err := readJSONDelim(dec, '{', path)
+	if err != nil {
+		return nil, err
+	}
+	var keys []string
+	var members []json.RawMessage
+	for dec.More() {
+		key, err := readJSONString(dec, path)
+		if err != nil {
+			return nil, err
+		}
+		var member json.RawMessage
+		err = dec.Decode(&member)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+		members = append(members, member)
+	}
+	err = readJSONDelim(dec, '}', path)
+	if err != nil {
+		return nil, err
+	}
+
+	node := &Node{}
+	hasName := false
+	for i, key := range keys {
+		if key == GenericName {
+			node.name, err = readJSONString(newJSONDecoder(members[i]), path)
+			if err != nil {
+				return nil, err
+			}
+			hasName = true
+		}
+	}
+	if !hasName {
+		return nil, jsonErr(path, "a node needs a name")
+	}
+
+	nodePath := childPath(path, node.name)
+	for i, key := range keys {
+		dec := newJSONDecoder(members[i])
+		switch key {
+		case GenericName:
+		case GenericType:
+			node.declaredType, err = readJSONString(dec, nodePath)
+		case GenericArgs:
+			err = readJSONDelim(dec, '[', nodePath)
+			for err == nil && dec.More() {
+				var value KDLValue
+				value, err = readValueJSON(dec, nodePath)
+				node.args = append(node.args, value)
+			}
+			if err == nil {
+				err = readJSONDelim(dec, ']', nodePath)
+			}
+		case GenericProps:
+			err = readJSONDelim(dec, '{', nodePath)
+			for err == nil && dec.More() {
+				var propKey string
+				var value KDLValue
+				propKey, err = readJSONString(dec, nodePath)
+				if err == nil {
+					value, err = readValueJSON(dec, nodePath)
+					node.AddProp(propKey, value)
+				}
+			}
+			if err == nil {
+				err = readJSONDelim(dec, '}', nodePath)
+			}
+		case GenericChildren:
+			node.children, err = readNodesJSON(dec, nodePath)
+		default:
+			err = jsonErr(nodePath, "unknown key "+key)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return node, nil
+}
+
+func readValueJSON(dec *json.Decoder, path string) (KDLValue, error) {
+	token, err := dec.Token()
+	if err != nil {
+		return KDLValue{}, err
+	}
+	if token != json.Delim('{') {
+		value, err := jsonLiteral(token)
+		if err != nil {
+			return KDLValue{}, jsonErr(path, "a value should be a literal or an object")
+		}
+		return value, nil
+	}
+
+	var value KDLValue
+	var declaredType string
+	hasValue := false
+	for dec.More() {
+		key, err := readJSONString(dec, path)
+		if err != nil {
+			return KDLValue{}, err
+		}
+		switch key {
+		case JSONValueType:
+			declaredType, err = readJSONString(dec, path)
+		case JSONValue:
+			value, err = readValueJSON(dec, path)
+			if err == nil && len(value.declaredType) > 0 {
+				err = jsonErr(path, "a value can only have one type annotation")
+			}
+			hasValue = true
+		case JSONValueNumber:
+			var number string
+			number, err = readJSONString(dec, path)
+			if err != nil {
+				return KDLValue{}, err
+			}
+			switch number {
+			case "#inf", "#-inf":
+				value = newInfValue(number == "#-inf")
+			case "#nan":
+				value = newNaNValue()
+			default:
+				err = jsonErr(path, "not an infinite or NaN number: "+number)
+			}
+			hasValue = true
+		default:
+			err = jsonErr(path, "unknown key "+key)
+		}
+		if err != nil {
+			return KDLValue{}, err
+		}
+	}
+	if !hasValue {
+		return KDLValue{}, jsonErr(path, "a value object needs a value")
+	}
+	value.declaredType = declaredType
+	return value, readJSONDelim(dec, '}', path)
+}
+
+func readJSONDelim(dec *json.Decoder, delim json.Delim, path string) error {
+	token, err := dec.Token()
+	if err != nil {
+		return err
+	}
+	if token != delim {
+		return jsonErr(path, "expected "+delim.String())
+	}
+	return nil
+}
+
+func readJSONString(dec *json.Decoder, path string) (string, error) {
+	token, err := dec.Token()
+	if err != nil {
+		return "", err
+	}
+	s, ok := token.(string)
+	if !ok {
+		return "", jsonErr(path, "expected a string")
+	}
+	return s, nil
+}
+
+func jsonErr(path string, reason string) error {
+	return &UnmarshalError{Kind: KDLInvalidJSON, Path: path, Err: errors.New(reason)}
+}
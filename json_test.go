@@ -0,0 +1,76 @@
+package kdlgo
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+func TestDocumentJSON(t *testing.T) {
+	input := `(dep)package "kdlgo" 18446744073709551616 2.50 #inf (u8)1 version=1 os="linux" version=2 {
+    child #null flag=#true (f64)#nan
+}
+`
+	doc, err := ParseDocumentString(input, WithVersion(KDLVersion2))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	b, err := json.Marshal(doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `[{"name":"package","type":"dep",` +
+		`"args":["kdlgo",18446744073709551616,2.50,{"number":"#inf"},{"type":"u8","value":1}],` +
+		`"props":{"os":"linux","version":2},` +
+		`"children":[{"name":"child","args":[null,{"type":"f64","number":"#nan"}],"props":{"flag":true}}]}]`
+	if string(b) != expected {
+		t.Errorf("Expected:\n%s\nGot:\n%s", expected, b)
+	}
+
+	var decoded Document
+	err = json.Unmarshal(b, &decoded)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err = json.Marshal(&decoded)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != expected {
+		t.Errorf("Expected the decoded document to marshal back to:\n%s\nGot:\n%s", expected, b)
+	}
+	if decoded.GetNodes()[0].GetArgs()[4].GetDeclaredType() != "u8" {
+		t.Error("Expected the type annotation of the value to be decoded")
+	}
+
+	var node Node
+	err = json.Unmarshal([]byte(`{"props":{"a":"b"},"name":"node"}`), &node)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if value, ok := node.GetProp("a"); node.GetName() != "node" || !ok || value.String != "b" {
+		t.Error("Node is incorrectly decoded from JSON")
+	}
+
+	invalid := []string{
+		`{}`,
+		`[{"args":[1]}]`,
+		`[{"name":"a","unknown":1}]`,
+		`[{"name":"a","args":[[1]]}]`,
+		`[{"name":"a","args":[{"type":"u8"}]}]`,
+		`[{"name":"a","args":[{"number":"1"}]}]`,
+		`[{"name":"a","props":[]}]`,
+	}
+	for _, s := range invalid {
+		if err := json.Unmarshal([]byte(s), &decoded); !errors.Is(err, KDLInvalidJSON) {
+			t.Errorf("Expected %s to be invalid, got %v", s, err)
+		}
+	}
+
+	err = json.Unmarshal([]byte(`[{"name":"a","children":[{"args":[[1]],"name":"late"}]}]`), &decoded)
+	var unmarshalErr *UnmarshalError
+	if !errors.As(err, &unmarshalErr) || unmarshalErr.Path != "a > late" {
+		t.Errorf("Expected the error to be at a > late, got %v", err)
+	}
+}
@@ -0,0 +1,643 @@
+package kdlgo
+
+import (
+	"encoding"
+	"errors"
+	"math"
+	"math/big"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+var (
+	errNonFiniteFloat = errors.New("KDL 1.0 has no infinite or NaN numbers")
+	errNoNode         = errors.New("arg, args and prop fields have no node to be written into here")
+	errCycle          = errors.New("the value refers to itself")
+)
+
+// Marshal returns the KDL encoding of v, formatted like Document.Format.
+//
+// Structs and maps become documents, with every field or map entry written as
+// a node. Maps are written in the order of their keys. Slices become a
+// document of "-" nodes, one per element.
+//
+// How a value is written as a node depends on its type: strings, numbers,
+// bools and nil become its only argument, slices of them become its arguments
+// and maps become its children. Structs have each field written according to
+// its `kdl` tag:
+//
+//	Name string   `kdl:"name"`      // a child node called "name" (the default)
+//	Port int      `kdl:"port,prop"` // a property, port=8080
+//	Host string   `kdl:",arg"`      // an argument
+//	Tags []string `kdl:",args"`     // an argument for every element
+//	Items []Item  `kdl:"item"`      // a child node called "item" per element
+//	Extra map[string]string `kdl:",children"` // a child node per entry
+//	Skipped int   `kdl:"-"`
+//
+// The name defaults to the name of the field. The `omitempty` option skips the
+// field if it is the zero value of its type, or an empty slice or map. The
+// fields of embedded structs are treated as fields of the outer struct unless
+// the embedded struct is given a name. Fields holding a *Node are written as
+// they are, under their own name.
+//
+// Infinite and NaN floats can't be written in KDL 1.0 and values that refer
+// to themselves can't be written at all. Both return a *MarshalError of kind
+// KDLUnsupportedType, as encoding/json does.
+//
+// Values implementing Marshaler write their own node and those implementing
+// ValueMarshaler their own value. Values implementing encoding.TextMarshaler
+// instead are written as strings.
+func Marshal(v interface{}) ([]byte, error) {
+	doc, err := newEncodeState().marshalDocument(reflect.ValueOf(v))
+	if err != nil {
+		return nil, err
+	}
+	s, err := doc.Format(FormatOptions{})
+	if err != nil {
+		return nil, err
+	}
+	return []byte(s), nil
+}
+
+// Encode writes the KDL encoding of v, as returned by Marshal.
+func (enc *Encoder) Encode(v interface{}) error {
+	if enc.err != nil {
+		return enc.err
+	}
+	doc, err := newEncodeState().marshalDocument(reflect.ValueOf(v))
+	if err != nil {
+		return err
+	}
+	return enc.EncodeDocument(doc)
+}
+
+type tagKind int
+
+const (
+	tagChild tagKind = iota
+	tagChildren
+	tagArg
+	tagArgs
+	tagProp
+)
+
+type fieldTag struct {
+	name      string
+	kind      tagKind
+	omitEmpty bool
+}
+
+type structField struct {
+	index []int
+	tag   fieldTag
+}
+
+var (
+	nodeType     = reflect.TypeOf(Node{})
+	documentType = reflect.TypeOf(Document{})
+	valueType    = reflect.TypeOf(KDLValue{})
+	bigIntType   = reflect.TypeOf(big.Int{})
+)
+
+// parseFieldTag reads the `kdl` tag of a field, reporting whether the field
+// should be encoded at all.
+func parseFieldTag(field reflect.StructField) (fieldTag, bool, error) {
+	tag := fieldTag{name: field.Name}
+	value, ok := field.Tag.Lookup("kdl")
+	if !ok {
+		return tag, true, nil
+	}
+	if value == "-" {
+		return tag, false, nil
+	}
+
+	options := strings.Split(value, ",")
+	if len(options[0]) > 0 {
+		tag.name = options[0]
+	}
+	for _, option := range options[1:] {
+		switch option {
+		case "child":
+			tag.kind = tagChild
+		case "children":
+			tag.kind = tagChildren
+		case "arg":
+			tag.kind = tagArg
+		case "args":
+			tag.kind = tagArgs
+		case "prop":
+			tag.kind = tagProp
+		case "omitempty":
+			tag.omitEmpty = true
+		default:
+			return tag, false, &MarshalError{Kind: KDLInvalidTag, Type: field.Type, Field: field.Name}
+		}
+	}
+	return tag, true, nil
+}
+
+// structFields returns the fields of a struct type that are encoded, with the
+// fields of embedded structs in place of the embedded struct.
+func structFields(t reflect.Type) ([]structField, error) {
+	var fields []structField
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.PkgPath != "" && (!field.Anonymous || field.Type.Kind() == reflect.Ptr) {
+			continue
+		}
+
+		tag, ok, err := parseFieldTag(field)
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			continue
+		}
+
+		fieldType := field.Type
+		if fieldType.Kind() == reflect.Ptr {
+			fieldType = fieldType.Elem()
+		}
+		_, hasName := field.Tag.Lookup("kdl")
+		if field.Anonymous && !hasName && fieldType.Kind() == reflect.Struct {
+			embedded, err := structFields(fieldType)
+			if err != nil {
+				return nil, err
+			}
+			for _, f := range embedded {
+				f.index = append([]int{i}, f.index...)
+				fields = append(fields, f)
+			}
+			continue
+		}
+		if field.PkgPath != "" {
+			continue
+		}
+		fields = append(fields, structField{index: []int{i}, tag: tag})
+	}
+	return fields, nil
+}
+
+// fieldByIndex is like reflect.Value.FieldByIndex, but reports whether the
+// field could be reached instead of panicking on nil embedded pointers.
+func fieldByIndex(v reflect.Value, index []int) (reflect.Value, bool) {
+	for i, x := range index {
+		if i > 0 && v.Kind() == reflect.Ptr {
+			if v.IsNil() {
+				return v, false
+			}
+			v = v.Elem()
+		}
+		v = v.Field(x)
+	}
+	return v, true
+}
+
+// indirect follows pointers and interfaces until it reaches a value that is
+// neither, or a nil one.
+func indirect(v reflect.Value) reflect.Value {
+	for (v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface) && !v.IsNil() {
+		v = v.Elem()
+	}
+	return v
+}
+
+func isNil(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Interface, reflect.Map, reflect.Slice:
+		return v.IsNil()
+	}
+	return !v.IsValid()
+}
+
+// isScalar reports whether v is written as a single value rather than as a
+// node or a list of values.
+func isScalar(v reflect.Value) bool {
+	v = indirect(v)
+	if !v.IsValid() || v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+		return true
+	}
+	return isScalarType(v.Type())
+}
+
+// isScalarType is like isScalar for when there is no value to go by, in which
+// case interfaces are assumed to hold scalars.
+func isScalarType(t reflect.Type) bool {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	switch t {
+	case valueType, bigIntType:
+		return true
+	}
+	if t.Kind() != reflect.Interface && implementsAny(t, marshalerType, unmarshalerType) {
+		return false
+	}
+	if implementsAny(t, valueMarshalerType, valueUnmarshalerType, textMarshalerType, textUnmarshalerType) {
+		return true
+	}
+	switch t.Kind() {
+	case reflect.Bool, reflect.String, reflect.Interface,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr,
+		reflect.Float32, reflect.Float64:
+		return true
+	}
+	return false
+}
+
+func isEmptyValue(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Array, reflect.Map, reflect.Slice, reflect.String:
+		return v.Len() == 0
+	case reflect.Ptr, reflect.Interface:
+		return v.IsNil()
+	}
+	return v.IsZero()
+}
+
+type encodeState struct {
+	// visiting holds the pointers, maps and slices being written, which
+	// can't be written again inside themselves.
+	visiting map[visitKey]struct{}
+}
+
+type visitKey struct {
+	ptr uintptr
+	typ reflect.Type
+	len int
+}
+
+func newEncodeState() *encodeState {
+	return &encodeState{visiting: map[visitKey]struct{}{}}
+}
+
+// enter marks the pointers, maps and slices v leads to as being written,
+// returning an error if one of them already is, as it would then have to be
+// written inside itself forever. The keys returned are unmarked by leave.
+func (e *encodeState) enter(v reflect.Value) ([]visitKey, error) {
+	var keys []visitKey
+	for v.IsValid() && !isNil(v) {
+		switch v.Kind() {
+		case reflect.Interface:
+			v = v.Elem()
+			continue
+		case reflect.Ptr, reflect.Map, reflect.Slice:
+		default:
+			return keys, nil
+		}
+
+		key := visitKey{ptr: v.Pointer(), typ: v.Type()}
+		if v.Kind() == reflect.Slice {
+			if v.Len() == 0 {
+				return keys, nil
+			}
+			key.len = v.Len()
+		}
+		if _, ok := e.visiting[key]; ok {
+			e.leave(keys)
+			return nil, &MarshalError{Kind: KDLUnsupportedType, Type: v.Type(), Err: errCycle}
+		}
+		e.visiting[key] = struct{}{}
+		keys = append(keys, key)
+		if v.Kind() != reflect.Ptr {
+			return keys, nil
+		}
+		v = v.Elem()
+	}
+	return keys, nil
+}
+
+func (e *encodeState) leave(keys []visitKey) {
+	for _, key := range keys {
+		delete(e.visiting, key)
+	}
+}
+
+func (e *encodeState) marshalDocument(v reflect.Value) (*Document, error) {
+	v = indirect(v)
+	if !v.IsValid() || isNil(v) {
+		return NewDocument(), nil
+	}
+	if v.Type() == documentType {
+		doc := v.Interface().(Document)
+		return &doc, nil
+	}
+
+	root := NewNode("")
+	var err error
+	if m, ok := marshalerFor(v, marshalerType); ok {
+		err = m.(Marshaler).MarshalKDL(root)
+		if err != nil {
+			return nil, &MarshalError{Kind: KDLMarshalerFailure, Type: v.Type(), Err: err}
+		}
+		return NewDocument(root.children...), nil
+	}
+	if v.Kind() == reflect.Slice || v.Kind() == reflect.Array {
+		err = e.marshalListChildren(root, v)
+	} else {
+		err = e.marshalChildren(root, v)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return NewDocument(root.children...), nil
+}
+
+// marshalNode writes v as a node with the given name.
+func (e *encodeState) marshalNode(name string, v reflect.Value) (*Node, error) {
+	keys, err := e.enter(v)
+	if err != nil {
+		return nil, err
+	}
+	defer e.leave(keys)
+
+	v = indirect(v)
+	if v.IsValid() && v.Type() == nodeType {
+		node := v.Interface().(Node)
+		return &node, nil
+	}
+
+	node := NewNode(name)
+	if m, ok := marshalerFor(v, marshalerType); ok {
+		err := m.(Marshaler).MarshalKDL(node)
+		if err != nil {
+			return nil, &MarshalError{Kind: KDLMarshalerFailure, Type: v.Type(), Err: err}
+		}
+		return node, nil
+	}
+	switch {
+	case isScalar(v):
+		value, err := marshalValue(v)
+		if err != nil {
+			return nil, err
+		}
+		node.AddArg(value)
+	case (v.Kind() == reflect.Slice || v.Kind() == reflect.Array) && isScalarList(v):
+		for i := 0; i < v.Len(); i++ {
+			value, err := marshalValue(v.Index(i))
+			if err != nil {
+				return nil, err
+			}
+			node.AddArg(value)
+		}
+	case v.Kind() == reflect.Struct:
+		err := e.marshalFields(node, v)
+		if err != nil {
+			return nil, err
+		}
+	default:
+		err := e.marshalChildren(node, v)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return node, nil
+}
+
+// marshalFields writes the fields of a struct into node according to their
+// tags.
+func (e *encodeState) marshalFields(node *Node, v reflect.Value) error {
+	fields, err := structFields(v.Type())
+	if err != nil {
+		return err
+	}
+
+	for _, field := range fields {
+		fv, ok := fieldByIndex(v, field.index)
+		if !ok || (field.tag.omitEmpty && isEmptyValue(fv)) {
+			continue
+		}
+
+		switch field.tag.kind {
+		case tagArg:
+			value, err := marshalValue(fv)
+			if err != nil {
+				return err
+			}
+			node.AddArg(value)
+		case tagArgs:
+			list := indirect(fv)
+			if isNil(list) {
+				continue
+			}
+			if list.Kind() != reflect.Slice && list.Kind() != reflect.Array {
+				return &MarshalError{Kind: KDLInvalidTag, Type: fv.Type(), Field: field.tag.name}
+			}
+			for i := 0; i < list.Len(); i++ {
+				value, err := marshalValue(list.Index(i))
+				if err != nil {
+					return err
+				}
+				node.AddArg(value)
+			}
+		case tagProp:
+			value, err := marshalValue(fv)
+			if err != nil {
+				return err
+			}
+			node.AddProp(field.tag.name, value)
+		case tagChildren:
+			keys, err := e.enter(fv)
+			if err != nil {
+				return err
+			}
+			err = e.marshalChildren(node, fv)
+			e.leave(keys)
+			if err != nil {
+				return err
+			}
+		default:
+			err := e.marshalChild(node, field.tag.name, fv)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// marshalChild adds v to node as a child with the given name, or as one child
+// per element if v is a list of anything other than scalars.
+func (e *encodeState) marshalChild(node *Node, name string, v reflect.Value) error {
+	list := indirect(v)
+	if (list.Kind() == reflect.Slice || list.Kind() == reflect.Array) && !isScalarList(list) {
+		for i := 0; i < list.Len(); i++ {
+			child, err := e.marshalNode(name, list.Index(i))
+			if err != nil {
+				return err
+			}
+			node.AddChild(child)
+		}
+		return nil
+	}
+
+	child, err := e.marshalNode(name, v)
+	if err != nil {
+		return err
+	}
+	node.AddChild(child)
+	return nil
+}
+
+// marshalChildren adds the fields of a struct, the entries of a map or the
+// elements of a list to node as its children.
+func (e *encodeState) marshalChildren(node *Node, v reflect.Value) error {
+	v = indirect(v)
+	if isNil(v) {
+		return nil
+	}
+
+	switch v.Kind() {
+	case reflect.Struct:
+		child := NewNode("")
+		err := e.marshalFields(child, v)
+		if err != nil {
+			return err
+		}
+		if len(child.args) > 0 || len(child.props) > 0 {
+			return &MarshalError{Kind: KDLUnsupportedType, Type: v.Type(), Field: entryField(v.Type()), Err: errNoNode}
+		}
+		node.children = append(node.children, child.children...)
+		return nil
+	case reflect.Map:
+		keys, err := sortedMapKeys(v)
+		if err != nil {
+			return err
+		}
+		for _, key := range keys {
+			err = e.marshalChild(node, key.name, v.MapIndex(key.value))
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	case reflect.Slice, reflect.Array:
+		return e.marshalListChildren(node, v)
+	}
+	return &MarshalError{Kind: KDLUnsupportedType, Type: v.Type()}
+}
+
+// entryField returns the name of the first field of a struct that is written
+// as an argument or property.
+func entryField(t reflect.Type) string {
+	fields, _ := structFields(t)
+	for _, field := range fields {
+		switch field.tag.kind {
+		case tagArg, tagArgs, tagProp:
+			return field.tag.name
+		}
+	}
+	return ""
+}
+
+// marshalListChildren adds every element of a list to node as a "-" child.
+func (e *encodeState) marshalListChildren(node *Node, v reflect.Value) error {
+	for i := 0; i < v.Len(); i++ {
+		child, err := e.marshalNode("-", v.Index(i))
+		if err != nil {
+			return err
+		}
+		node.AddChild(child)
+	}
+	return nil
+}
+
+// isScalarList reports whether every element of a list is a scalar, going by
+// the element type if the list is empty.
+func isScalarList(v reflect.Value) bool {
+	if v.Len() == 0 {
+		return isScalarType(v.Type().Elem())
+	}
+	for i := 0; i < v.Len(); i++ {
+		if !isScalar(v.Index(i)) {
+			return false
+		}
+	}
+	return true
+}
+
+type mapKey struct {
+	name  string
+	value reflect.Value
+}
+
+// sortedMapKeys returns the keys of a map along with their names, sorted by
+// name so that maps are always written in the same order.
+func sortedMapKeys(v reflect.Value) ([]mapKey, error) {
+	var keys []mapKey
+	for _, key := range v.MapKeys() {
+		var name string
+		switch key.Kind() {
+		case reflect.String:
+			name = key.String()
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			name = strconv.FormatInt(key.Int(), 10)
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+			name = strconv.FormatUint(key.Uint(), 10)
+		default:
+			return nil, &MarshalError{Kind: KDLUnsupportedType, Type: v.Type()}
+		}
+		keys = append(keys, mapKey{name: name, value: key})
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		return keys[i].name < keys[j].name
+	})
+	return keys, nil
+}
+
+// marshalValue converts a scalar into a KDLValue.
+func marshalValue(v reflect.Value) (KDLValue, error) {
+	v = indirect(v)
+	if isNil(v) {
+		return NewKDLNull("").GetValue(), nil
+	}
+
+	switch v.Type() {
+	case valueType:
+		return v.Interface().(KDLValue), nil
+	case bigIntType:
+		integer := v.Interface().(big.Int)
+		return NewKDLBigInt("", &integer).GetValue(), nil
+	}
+
+	if m, ok := marshalerFor(v, valueMarshalerType); ok {
+		value, err := m.(ValueMarshaler).MarshalKDLValue()
+		if err != nil {
+			return value, &MarshalError{Kind: KDLMarshalerFailure, Type: v.Type(), Err: err}
+		}
+		return value, nil
+	}
+	if m, ok := marshalerFor(v, textMarshalerType); ok {
+		text, err := m.(encoding.TextMarshaler).MarshalText()
+		if err != nil {
+			return KDLValue{}, &MarshalError{Kind: KDLMarshalerFailure, Type: v.Type(), Err: err}
+		}
+		return KDLValue{String: string(text), Type: KDLStringType}, nil
+	}
+
+	switch v.Kind() {
+	case reflect.Bool:
+		return NewKDLBool("", v.Bool()).GetValue(), nil
+	case reflect.String:
+		// NewKDLString would decode escapes in the string.
+		return KDLValue{String: v.String(), Type: KDLStringType}, nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return NewKDLInt("", v.Int()).GetValue(), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return NewKDLUint("", v.Uint()).GetValue(), nil
+	case reflect.Float32, reflect.Float64:
+		f := v.Float()
+		if math.IsInf(f, 0) || math.IsNaN(f) {
+			return KDLValue{}, &MarshalError{Kind: KDLUnsupportedType, Type: v.Type(), Err: errNonFiniteFloat}
+		}
+		if v.Kind() == reflect.Float32 {
+			dec, err := NewKDLDecimal("", strconv.FormatFloat(f, 'g', -1, 32))
+			return dec.GetValue(), err
+		}
+		return NewKDLNumber("", f).GetValue(), nil
+	}
+	return KDLValue{}, &MarshalError{Kind: KDLUnsupportedType, Type: v.Type()}
+}
@@ -0,0 +1,197 @@
+package kdlgo
+
+import (
+	"errors"
+	"math"
+	"math/big"
+	"testing"
+)
+
+type marshalServer struct {
+	Host    string            `kdl:",arg"`
+	Port    int               `kdl:"port,prop"`
+	TLS     bool              `kdl:"tls,prop,omitempty"`
+	Aliases []string          `kdl:",args"`
+	Headers map[string]string `kdl:"headers"`
+	Routes  []marshalRoute    `kdl:"route"`
+	Limit   *int              `kdl:"limit,omitempty"`
+	Ignored string            `kdl:"-"`
+	private string
+}
+
+type marshalRoute struct {
+	Path   string   `kdl:",arg"`
+	Method []string `kdl:"method"`
+}
+
+type MarshalMeta struct {
+	Version uint8   `kdl:"version"`
+	Ratio   float32 `kdl:"ratio"`
+}
+
+type marshalConfig struct {
+	MarshalMeta
+	Name    string                 `kdl:"name"`
+	Servers []marshalServer        `kdl:"server"`
+	Extra   map[string]interface{} `kdl:",children"`
+	Big     *big.Int               `kdl:"big"`
+	Missing *marshalRoute          `kdl:"missing"`
+}
+
+func TestMarshal(t *testing.T) {
+	config := marshalConfig{
+		MarshalMeta: MarshalMeta{Version: 2, Ratio: 0.1},
+		Name:        "example \"config\"",
+		Servers: []marshalServer{
+			{
+				Host:    "localhost",
+				Port:    8080,
+				Aliases: []string{"local", "loopback"},
+				Headers: map[string]string{"b": "2", "a": "1"},
+				Routes: []marshalRoute{
+					{Path: "/", Method: []string{"GET", "HEAD"}},
+					{Path: "/submit"},
+				},
+				private: "unused",
+			},
+			{Host: "0.0.0.0", Port: 443, TLS: true},
+		},
+		Extra: map[string]interface{}{
+			"debug": true,
+			"list":  []interface{}{1, "two", nil},
+			"nested": map[string]int{
+				"x": 1,
+			},
+		},
+		Big: new(big.Int).Lsh(big.NewInt(1), 70),
+	}
+
+	data, err := Marshal(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `version 2
+ratio 0.1
+name "example \"config\""
+server "localhost" "local" "loopback" port=8080 {
+    headers {
+        a "1"
+        b "2"
+    }
+    route "/" {
+        method "GET" "HEAD"
+    }
+    route "/submit" {
+        method
+    }
+}
+server "0.0.0.0" port=443 tls=true {
+    headers
+}
+debug true
+list 1 "two" null
+nested {
+    x 1
+}
+big 1180591620717411303424
+missing null
+`
+	if string(data) != expected {
+		t.Errorf("Expected:\n%s\nGot:\n%s", expected, string(data))
+	}
+	if _, err := ParseDocumentString(string(data)); err != nil {
+		t.Error("Marshaled output doesn't parse:", err)
+	}
+}
+
+func TestMarshalTopLevel(t *testing.T) {
+	data, err := Marshal([]interface{}{1, []string{"a", "b"}, map[string]bool{"on": true}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := "- 1\n- \"a\" \"b\"\n- {\n    on true\n}\n"
+	if string(data) != expected {
+		t.Errorf("Expected:\n%s\nGot:\n%s", expected, string(data))
+	}
+
+	node := NewNode("custom")
+	node.AddProp("key", NewKDLBool("", false).GetValue())
+	data, err = Marshal(map[string]interface{}{"wrapper": node})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "custom key=false\n" {
+		t.Errorf("Nodes should be written as they are, got %q", string(data))
+	}
+}
+
+func TestMarshalErrors(t *testing.T) {
+	tests := []interface{}{
+		map[string]interface{}{"fn": func() {}},
+		struct {
+			C chan int `kdl:",arg"`
+		}{},
+		struct {
+			A int `kdl:",bogus"`
+		}{},
+		struct {
+			A int `kdl:",arg"`
+		}{},
+		map[float64]int{1: 1},
+	}
+	for _, test := range tests {
+		_, err := Marshal(test)
+		var marshalErr *MarshalError
+		if !errors.As(err, &marshalErr) {
+			t.Errorf("Expected a *MarshalError for %T, got %v", test, err)
+		}
+	}
+
+	_, err := Marshal(struct {
+		Port int `kdl:"port,prop"`
+	}{80})
+	var marshalErr *MarshalError
+	if !errors.As(err, &marshalErr) || marshalErr.Kind != KDLUnsupportedType || marshalErr.Field != "port" {
+		t.Errorf("Expected KDLUnsupportedType for the port field, got %v", err)
+	}
+
+	type cyclic struct {
+		Name     string  `kdl:"name"`
+		Next     *cyclic `kdl:"next"`
+		Children *cyclic `kdl:",children"`
+	}
+	next := &cyclic{Name: "a"}
+	next.Next = next
+	children := &cyclic{Name: "b"}
+	children.Children = children
+	m := map[string]interface{}{}
+	m["self"] = m
+	list := []interface{}{nil}
+	list[0] = list
+	for _, test := range []interface{}{next, children, m, list} {
+		_, err := Marshal(test)
+		if !errors.Is(err, KDLUnsupportedType) {
+			t.Errorf("Expected KDLUnsupportedType for a cycle in %T, got %v", test, err)
+		}
+	}
+
+	shared := &cyclic{Name: "shared"}
+	s, err := Marshal(map[string]*cyclic{"x": shared, "y": shared})
+	if err != nil || string(s) != "x {\n    name \"shared\"\n    next null\n}\ny {\n    name \"shared\"\n    next null\n}\n" {
+		t.Errorf("Expected a value referred to twice to be written twice, got %q, %v", s, err)
+	}
+
+	nonFinite := []interface{}{
+		map[string]float64{"inf": math.Inf(1)},
+		[]float32{float32(math.Inf(-1))},
+		struct {
+			N float64 `kdl:"n,prop"`
+		}{math.NaN()},
+	}
+	for _, test := range nonFinite {
+		_, err := Marshal(test)
+		if !errors.Is(err, KDLUnsupportedType) {
+			t.Errorf("Expected KDLUnsupportedType for %v, got %v", test, err)
+		}
+	}
+}
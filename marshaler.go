@@ -0,0 +1,88 @@
+package kdlgo
+
+import (
+	"encoding"
+	"reflect"
+)
+
+// Marshaler is implemented by types that write themselves as a node. The node
+// is created with the name of the field or map key the value is in, and
+// MarshalKDL adds the arguments, properties and children to it.
+type Marshaler interface {
+	MarshalKDL(node *Node) error
+}
+
+// Unmarshaler is implemented by types that read themselves from a node, the
+// reverse of Marshaler.
+type Unmarshaler interface {
+	UnmarshalKDL(node *Node) error
+}
+
+// ValueMarshaler is implemented by types that write themselves as a single
+// value, wherever a string or number would be written.
+type ValueMarshaler interface {
+	MarshalKDLValue() (KDLValue, error)
+}
+
+// ValueUnmarshaler is implemented by types that read themselves from a single
+// value, the reverse of ValueMarshaler. It is called for null values as well,
+// unless the value is a pointer, which is set to nil instead.
+type ValueUnmarshaler interface {
+	UnmarshalKDLValue(value KDLValue) error
+}
+
+// Types implementing encoding.TextMarshaler and encoding.TextUnmarshaler
+// without implementing any of the interfaces above are written as strings.
+var (
+	marshalerType        = reflect.TypeOf((*Marshaler)(nil)).Elem()
+	unmarshalerType      = reflect.TypeOf((*Unmarshaler)(nil)).Elem()
+	valueMarshalerType   = reflect.TypeOf((*ValueMarshaler)(nil)).Elem()
+	valueUnmarshalerType = reflect.TypeOf((*ValueUnmarshaler)(nil)).Elem()
+	textMarshalerType    = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+	textUnmarshalerType  = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+)
+
+// implementsAny reports whether t or a pointer to it implements any of the
+// interfaces.
+func implementsAny(t reflect.Type, ifaces ...reflect.Type) bool {
+	for _, iface := range ifaces {
+		if t.Implements(iface) || (t.Kind() != reflect.Interface && reflect.PtrTo(t).Implements(iface)) {
+			return true
+		}
+	}
+	return false
+}
+
+// marshalerFor returns v as iface if it implements it, making an addressable
+// copy of v for methods with a pointer receiver. Nil pointers never implement
+// anything, as they are written as null.
+func marshalerFor(v reflect.Value, iface reflect.Type) (interface{}, bool) {
+	v = indirect(v)
+	if !v.IsValid() || isNil(v) && v.Kind() != reflect.Map && v.Kind() != reflect.Slice {
+		return nil, false
+	}
+	if v.Kind() != reflect.Interface && v.Type().Implements(iface) && v.CanInterface() {
+		return v.Interface(), true
+	}
+	if v.Kind() != reflect.Interface && reflect.PtrTo(v.Type()).Implements(iface) && v.CanInterface() {
+		ptr := reflect.New(v.Type())
+		ptr.Elem().Set(v)
+		return ptr.Interface(), true
+	}
+	return nil, false
+}
+
+// unmarshalerFor returns v, which must not be a pointer, as iface if it or a
+// pointer to it implements it.
+func unmarshalerFor(v reflect.Value, iface reflect.Type) (interface{}, bool) {
+	if v.Kind() == reflect.Interface || !v.CanInterface() {
+		return nil, false
+	}
+	if v.CanAddr() && reflect.PtrTo(v.Type()).Implements(iface) {
+		return v.Addr().Interface(), true
+	}
+	if v.Type().Implements(iface) {
+		return v.Interface(), true
+	}
+	return nil, false
+}
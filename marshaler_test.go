@@ -0,0 +1,131 @@
+package kdlgo
+
+import (
+	"errors"
+	"net"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testDuration is written as a number of seconds.
+type testDuration time.Duration
+
+func (duration testDuration) MarshalKDLValue() (KDLValue, error) {
+	return NewKDLInt("", int64(time.Duration(duration)/time.Second)).GetValue(), nil
+}
+
+func (duration *testDuration) UnmarshalKDLValue(value KDLValue) error {
+	seconds, ok := value.Int64()
+	if !ok {
+		return errors.New("expected a number of seconds")
+	}
+	*duration = testDuration(time.Duration(seconds) * time.Second)
+	return nil
+}
+
+// testByteSize is written as text like "10KB".
+type testByteSize int64
+
+func (size testByteSize) MarshalText() ([]byte, error) {
+	return []byte(strconv.FormatInt(int64(size)/1024, 10) + "KB"), nil
+}
+
+func (size *testByteSize) UnmarshalText(text []byte) error {
+	n, err := strconv.ParseInt(strings.TrimSuffix(string(text), "KB"), 10, 64)
+	*size = testByteSize(n * 1024)
+	return err
+}
+
+// testSelector is written as a node with the kind as type annotation and a
+// property per label.
+type testSelector struct {
+	Kind   string
+	Labels map[string]string
+}
+
+func (selector testSelector) MarshalKDL(node *Node) error {
+	node.AddArg(KDLValue{String: selector.Kind, Type: KDLStringType})
+	keys, _ := sortedMapKeys(reflect.ValueOf(selector.Labels))
+	for _, key := range keys {
+		node.AddProp(key.name, KDLValue{String: selector.Labels[key.name], Type: KDLStringType})
+	}
+	return nil
+}
+
+func (selector *testSelector) UnmarshalKDL(node *Node) error {
+	if len(node.GetArgs()) != 1 {
+		return errors.New("expected the kind")
+	}
+	selector.Kind = node.GetArgs()[0].String
+	selector.Labels = map[string]string{}
+	for _, prop := range node.GetProps() {
+		selector.Labels[prop.GetKey()] = prop.GetValue().String
+	}
+	return nil
+}
+
+type marshalerConfig struct {
+	Timeout   testDuration   `kdl:"timeout"`
+	MaxSize   testByteSize   `kdl:"max-size"`
+	Address   net.IP         `kdl:"address"`
+	Selectors []testSelector `kdl:"selector"`
+	Started   time.Time      `kdl:"started"`
+}
+
+func TestMarshaler(t *testing.T) {
+	config := marshalerConfig{
+		Timeout: testDuration(90 * time.Second),
+		MaxSize: 10 * 1024,
+		Address: net.ParseIP("10.0.0.1"),
+		Selectors: []testSelector{
+			{Kind: "pod", Labels: map[string]string{"app": "web", "tier": "front"}},
+		},
+		Started: time.Date(2021, 3, 4, 5, 6, 7, 0, time.UTC),
+	}
+
+	data, err := Marshal(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `timeout 90
+max-size "10KB"
+address "10.0.0.1"
+selector "pod" app="web" tier="front"
+started "2021-03-04T05:06:07Z"
+`
+	if string(data) != expected {
+		t.Errorf("Expected:\n%s\nGot:\n%s", expected, string(data))
+	}
+
+	var decoded marshalerConfig
+	err = Unmarshal(data, &decoded)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(config, decoded) {
+		t.Errorf("Expected:\n%#v\nGot:\n%#v", config, decoded)
+	}
+}
+
+func TestMarshalerErrors(t *testing.T) {
+	var config marshalerConfig
+	err := Unmarshal([]byte("timeout \"soon\""), &config)
+	var unmarshalErr *UnmarshalError
+	if !errors.As(err, &unmarshalErr) || unmarshalErr.Kind != KDLMarshalerFailure ||
+		unmarshalErr.Path != "timeout" || unmarshalErr.Err == nil {
+		t.Errorf("Expected the unmarshaler's error, got %v", err)
+	}
+
+	err = Unmarshal([]byte("max-size 10"), &config)
+	if !errors.Is(err, KDLTypeMismatch) {
+		t.Errorf("Expected a type mismatch for a TextUnmarshaler, got %v", err)
+	}
+
+	err = Unmarshal([]byte("selector"), &config)
+	if !errors.Is(err, KDLMarshalerFailure) || errors.Unwrap(err).Error() != "expected the kind" {
+		t.Errorf("Expected the node unmarshaler's error, got %v", err)
+	}
+}
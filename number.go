@@ -0,0 +1,272 @@
+package kdlgo
+
+import (
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+type KDLNumberKind string
+
+const (
+	KDLIntegerKind = "kdl_integer"
+	KDLFloatKind   = "kdl_float"
+)
+
+// decimalPrecision is the mantissa precision, in bits, used when a decimal
+// has to be represented as a big.Float.
+const decimalPrecision = 256
+
+// maxRatExponent is the largest power of ten kdlDecimal.Rat scales by. Past
+// it, computing the exact value takes longer than it could ever be worth.
+const maxRatExponent = 10000
+
+// maxDecimalExponent is the largest exponent a kdlDecimal keeps. Larger ones
+// are capped to it, which is already beyond the range of a big.Float.
+const maxDecimalExponent = math.MaxInt32
+
+// kdlDecimal is an exact base 10 number with the value
+// unscaled * 10^-scale * 10^exponent. The scale and exponent are kept as
+// written so the number can be reproduced without any rounding, unless the
+// exponent has to be capped to ±maxDecimalExponent.
+type kdlDecimal struct {
+	unscaled    *big.Int
+	scale       int
+	exponent    int
+	hasExponent bool
+	// isNegativeZero keeps the sign of a zero, which unscaled can't hold.
+	isNegativeZero bool
+}
+
+// parseDecimal parses a decimal literal with its underscores already removed,
+// e.g. "-1.23e+10".
+func parseDecimal(str string) (*kdlDecimal, error) {
+	dec := &kdlDecimal{}
+	mantissa := str
+	if i := strings.IndexAny(str, "eE"); i >= 0 {
+		exponent, ok := new(big.Int).SetString(str[i+1:], 10)
+		if !ok {
+			return nil, invalidNumValueErr()
+		}
+		mantissa = str[:i]
+		dec.exponent = capExponent(exponent)
+		dec.hasExponent = true
+	}
+
+	if i := strings.IndexRune(mantissa, dot); i >= 0 {
+		dec.scale = len(mantissa) - i - 1
+		mantissa = mantissa[:i] + mantissa[i+1:]
+	}
+
+	unscaled, ok := new(big.Int).SetString(mantissa, 10)
+	if !ok {
+		return nil, invalidNumValueErr()
+	}
+	dec.unscaled = unscaled
+	dec.isNegativeZero = unscaled.Sign() == 0 && strings.HasPrefix(mantissa, "-")
+	return dec, nil
+}
+
+func (dec *kdlDecimal) String() string {
+	var s strings.Builder
+	if dec.unscaled.Sign() < 0 || dec.isNegativeZero {
+		s.WriteRune(dash)
+	}
+
+	digits := new(big.Int).Abs(dec.unscaled).String()
+	if len(digits) <= dec.scale {
+		digits = strings.Repeat("0", dec.scale-len(digits)+1) + digits
+	}
+	if dec.scale > 0 {
+		s.WriteString(digits[:len(digits)-dec.scale] + "." + digits[len(digits)-dec.scale:])
+	} else {
+		s.WriteString(digits)
+		if !dec.hasExponent {
+			s.WriteString(".0")
+		}
+	}
+
+	if dec.hasExponent {
+		s.WriteRune('E')
+		if dec.exponent >= 0 {
+			s.WriteRune('+')
+		}
+		s.WriteString(strconv.Itoa(dec.exponent))
+	}
+	return s.String()
+}
+
+// Rat returns the exact value of the decimal, or false if it would have to be
+// scaled by more than 10^maxRatExponent, e.g. 1e99999999.
+func (dec *kdlDecimal) Rat() (*big.Rat, bool) {
+	rat := new(big.Rat).SetInt(dec.unscaled)
+	exponent := dec.exponent - dec.scale
+	if dec.unscaled.Sign() == 0 {
+		return rat, true
+	}
+	if abs(exponent) > maxRatExponent {
+		return nil, false
+	}
+	pow := new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(abs(exponent))), nil)
+	if exponent >= 0 {
+		return rat.Mul(rat, new(big.Rat).SetInt(pow)), true
+	}
+	return rat.Quo(rat, new(big.Rat).SetInt(pow)), true
+}
+
+// Float returns the decimal rounded to decimalPrecision bits. Decimals with an
+// exponent beyond the range of a big.Float saturate to ±Inf, or to ±0 if the
+// exponent is negative.
+func (dec *kdlDecimal) Float() *big.Float {
+	f, _, err := big.ParseFloat(dec.String(), 10, decimalPrecision, big.ToNearestEven)
+	if err == nil {
+		return f
+	}
+	isNegative := dec.unscaled.Sign() < 0 || dec.isNegativeZero
+	f = new(big.Float).SetPrec(decimalPrecision)
+	if dec.exponent < 0 {
+		if isNegative {
+			f.Neg(f)
+		}
+		return f
+	}
+	return f.SetInf(isNegative)
+}
+
+func capExponent(exponent *big.Int) int {
+	switch {
+	case exponent.Cmp(big.NewInt(maxDecimalExponent)) > 0:
+		return maxDecimalExponent
+	case exponent.Cmp(big.NewInt(-maxDecimalExponent)) < 0:
+		return -maxDecimalExponent
+	}
+	return int(exponent.Int64())
+}
+
+func abs(i int) int {
+	if i < 0 {
+		return -i
+	}
+	return i
+}
+
+func newIntegerValue(value *big.Int) KDLValue {
+	prec := uint(value.BitLen())
+	if prec < 64 {
+		prec = 64
+	}
+	return KDLValue{
+		Number:     *new(big.Float).SetPrec(prec).SetInt(value),
+		Type:       KDLNumberType,
+		numberKind: KDLIntegerKind,
+		integer:    value,
+	}
+}
+
+func newDecimalValue(dec *kdlDecimal) KDLValue {
+	return KDLValue{
+		Number:     *dec.Float(),
+		Type:       KDLNumberType,
+		numberKind: KDLFloatKind,
+		decimal:    dec,
+	}
+}
+
+// newNaNValue returns the KDL 2.0 `#nan` keyword, which big.Float can't
+// represent.
+func newNaNValue() KDLValue {
+	return KDLValue{Type: KDLNumberType, numberKind: KDLFloatKind, nan: true}
+}
+
+func newInfValue(negative bool) KDLValue {
+	return KDLValue{
+		Number:     *new(big.Float).SetInf(negative),
+		Type:       KDLNumberType,
+		numberKind: KDLFloatKind,
+	}
+}
+
+// GetNumberKind reports whether a KDLNumberType value was written as an
+// integer or as a float.
+func (kdlValue KDLValue) GetNumberKind() KDLNumberKind {
+	return kdlValue.numberKind
+}
+
+// Int64 returns the value as an int64 if it is an integer that fits.
+func (kdlValue KDLValue) Int64() (int64, bool) {
+	if kdlValue.integer == nil || !kdlValue.integer.IsInt64() {
+		return 0, false
+	}
+	return kdlValue.integer.Int64(), true
+}
+
+// Uint64 returns the value as a uint64 if it is an integer that fits.
+func (kdlValue KDLValue) Uint64() (uint64, bool) {
+	if kdlValue.integer == nil || !kdlValue.integer.IsUint64() {
+		return 0, false
+	}
+	return kdlValue.integer.Uint64(), true
+}
+
+// BigInt returns a copy of the value if it is an integer.
+func (kdlValue KDLValue) BigInt() (*big.Int, bool) {
+	if kdlValue.integer == nil {
+		return nil, false
+	}
+	return new(big.Int).Set(kdlValue.integer), true
+}
+
+// BigRat returns the exact value of any number, or false if the value isn't
+// one or is a decimal whose exponent is too large to compute it exactly, e.g.
+// 1e99999999.
+func (kdlValue KDLValue) BigRat() (*big.Rat, bool) {
+	switch {
+	case kdlValue.integer != nil:
+		return new(big.Rat).SetInt(kdlValue.integer), true
+	case kdlValue.decimal != nil:
+		return kdlValue.decimal.Rat()
+	case kdlValue.Type == KDLNumberType && !kdlValue.nan:
+		rat, _ := kdlValue.Number.Rat(nil)
+		return rat, rat != nil
+	}
+	return nil, false
+}
+
+// Float64 returns the nearest float64 to the value.
+func (kdlValue KDLValue) Float64() float64 {
+	if kdlValue.nan {
+		return math.NaN()
+	}
+	f, _ := kdlValue.Number.Float64()
+	return f
+}
+
+// fitFloat returns the value as a float of the given bit size, or false if
+// it is too large for one. Only #inf and #-inf become infinities, never a
+// decimal or integer that overflows.
+func fitFloat(value KDLValue, bitSize int) (float64, bool) {
+	f := value.Float64()
+	if math.IsInf(f, 0) {
+		return f, value.decimal == nil && value.integer == nil
+	}
+	return f, bitSize != 32 || math.Abs(f) <= math.MaxFloat32
+}
+
+// NumberString returns the exact textual representation of the number.
+func (kdlValue KDLValue) NumberString() string {
+	switch {
+	case kdlValue.integer != nil:
+		return kdlValue.integer.String()
+	case kdlValue.decimal != nil:
+		return kdlValue.decimal.String()
+	case kdlValue.nan:
+		return "#nan"
+	case kdlValue.Number.IsInf():
+		if kdlValue.Number.Signbit() {
+			return "#-inf"
+		}
+		return "#inf"
+	}
+	return kdlValue.Number.Text('f', -1)
+}
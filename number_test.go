@@ -0,0 +1,179 @@
+package kdlgo
+
+import (
+	"math"
+	"math/big"
+	"testing"
+)
+
+func TestNumberPrecision(t *testing.T) {
+	doc, err := ParseDocumentString("node 0xabcdef1234567890 1.23E+1000 0.1 -9223372036854775808 12 1.0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	args := doc.GetNodes()[0].GetArgs()
+
+	if _, ok := args[0].Int64(); ok {
+		t.Error("0xabcdef1234567890 should not fit in an int64")
+	}
+	u, ok := args[0].Uint64()
+	if !ok || u != 0xabcdef1234567890 {
+		t.Error("0xabcdef1234567890 was not parsed exactly")
+	}
+
+	expected := []string{"12379813812177893520", "1.23E+1000", "0.1", "-9223372036854775808", "12", "1.0"}
+	kinds := []KDLNumberKind{KDLIntegerKind, KDLFloatKind, KDLFloatKind, KDLIntegerKind, KDLIntegerKind, KDLFloatKind}
+	for i, arg := range args {
+		s, err := arg.RecreateKDL()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if s != expected[i] {
+			t.Error("Expected: '" + expected[i] + "' but got '" + s + "' instead")
+		}
+		if arg.GetNumberKind() != kinds[i] {
+			t.Error("Expected '" + expected[i] + "' to be of kind " + string(kinds[i]))
+		}
+	}
+
+	rat, ok := args[2].BigRat()
+	if !ok || rat.Cmp(big.NewRat(1, 10)) != 0 {
+		t.Error("0.1 was not kept exactly")
+	}
+	if i, ok := args[3].Int64(); !ok || i != -9223372036854775808 {
+		t.Error("-9223372036854775808 was not parsed exactly")
+	}
+}
+
+func TestNegativeZero(t *testing.T) {
+	doc, err := ParseDocumentString("node -0.0 -0e5 0.0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	args := doc.GetNodes()[0].GetArgs()
+
+	expected := []string{"-0.0", "-0E+5", "0.0"}
+	for i, arg := range args {
+		s, err := arg.RecreateKDL()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if s != expected[i] {
+			t.Error("Expected: '" + expected[i] + "' but got '" + s + "' instead")
+		}
+		if isNegative := math.Signbit(arg.Float64()); isNegative != (i < 2) {
+			t.Error("Expected the sign of '" + expected[i] + "' to be kept in its float64")
+		}
+	}
+}
+
+func TestHugeExponent(t *testing.T) {
+	doc, err := ParseDocumentString("node 1e2147483647 -1e2147483647 1e-9999999999 -1e-9999999999")
+	if err != nil {
+		t.Fatal(err)
+	}
+	args := doc.GetNodes()[0].GetArgs()
+
+	expected := []float64{math.Inf(1), math.Inf(-1), 0, math.Copysign(0, -1)}
+	for i, arg := range args {
+		f := arg.Float64()
+		if f != expected[i] || math.Signbit(f) != math.Signbit(expected[i]) {
+			t.Errorf("Expected %s to saturate to %v but got %v", arg.NumberString(), expected[i], f)
+		}
+	}
+}
+
+func TestNumberConstructors(t *testing.T) {
+	dec, err := NewKDLDecimal("key", "3.141_592_653_589_793_238_462_643")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	values := []KDLValue{
+		NewKDLNumber("key", 1.5).GetValue(),
+		NewKDLNumber("key", 2).GetValue(),
+		NewKDLInt("key", -42).GetValue(),
+		NewKDLUint("key", 18446744073709551615).GetValue(),
+		dec.GetValue(),
+	}
+	expected := []string{"1.5", "2.0", "-42", "18446744073709551615", "3.141592653589793238462643"}
+	for i, value := range values {
+		s, err := value.RecreateKDL()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if s != expected[i] {
+			t.Error("Expected: '" + expected[i] + "' but got '" + s + "' instead")
+		}
+	}
+}
+
+func TestNumberGrammar(t *testing.T) {
+	literals := map[string]string{
+		"-10":                      "-10",
+		"+5":                       "5",
+		"0x_10":                    "",
+		"0xABC_def_":               "11259375",
+		"-0o7_7":                   "-63",
+		"0b1_0_":                   "2",
+		"1_000.000_1":              "1000.0001",
+		"1.0e-10_0":                "1.0E-100",
+		"-1E+5":                    "-1E+5",
+		"1e99999999999999999999":   "1E+2147483647",
+		"-1e-99999999999999999999": "-1E-2147483647",
+		"1e++5":                    "",
+		"1_":                       "1",
+		"0x":                       "",
+		"0xx10":                    "",
+		"0x10g10":                  "",
+		"0o45678":                  "",
+		"0bx01":                    "",
+		"0b2":                      "",
+		"1.":                       "",
+		"1._7":                     "",
+		"1.e7":                     "",
+		"1.0.0":                    "",
+		"1.0E10e10":                "",
+		"1e":                       "",
+		"1e+":                      "",
+		"1e_1":                     "",
+		"+":                        "",
+		"1__":                      "1",
+		"10.0_":                    "10.0",
+		"0o12_3456_7_":             "342391",
+	}
+
+	for literal, expected := range literals {
+		value, err := parseNumberString(literal)
+		if len(expected) < 1 {
+			if err == nil {
+				t.Error("Expected '" + literal + "' to be rejected")
+			}
+			continue
+		}
+		if err != nil {
+			t.Error("Expected '" + literal + "' to be accepted but got: " + err.Error())
+			continue
+		}
+		if value.NumberString() != expected {
+			t.Error("Expected: '" + expected + "' but got '" + value.NumberString() + "' instead")
+		}
+	}
+
+	huge, err := parseNumberString("1e99999999999999999999")
+	if err != nil || !math.IsInf(huge.Float64(), 1) {
+		t.Error("Expected an exponent too large for an int to saturate to +Inf")
+	}
+
+	doc, err := ParseDocumentString("node -10 prop=-15 +1.5")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := doc.GetNodes()[0].RecreateKDL()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s != "node -10 1.5 prop=-15" {
+		t.Error("Expected: 'node -10 1.5 prop=-15' but got '" + s + "' instead")
+	}
+}
@@ -0,0 +1,47 @@
+package kdlgo
+
+// ParseOption configures the ParseDocument functions.
+type ParseOption func(*parseOptions)
+
+// KDLVersion is the version of the KDL spec a document is parsed as.
+type KDLVersion int
+
+const (
+	// KDLVersionAuto parses a document as the version declared by its leading
+	// `/- kdl-version` marker. Documents without one are parsed as KDL 2.0 and,
+	// if that fails, as KDL 1.0.
+	KDLVersionAuto KDLVersion = iota
+	KDLVersion1
+	KDLVersion2
+)
+
+type parseOptions struct {
+	recover bool
+	version KDLVersion
+}
+
+func newParseOptions(opts []ParseOption) parseOptions {
+	options := parseOptions{version: KDLVersion1}
+	for _, opt := range opts {
+		opt(&options)
+	}
+	return options
+}
+
+// WithRecovery makes the parser skip to the end of any node it fails to parse
+// and carry on with the next one instead of stopping at the first error.
+// Parsing then returns the partially parsed document along with a ParseErrors
+// holding every error that was found.
+func WithRecovery() ParseOption {
+	return func(options *parseOptions) {
+		options.recover = true
+	}
+}
+
+// WithVersion sets the version of the KDL spec to parse the document as.
+// Documents are parsed as KDL 1.0 by default.
+func WithVersion(version KDLVersion) ParseOption {
+	return func(options *parseOptions) {
+		options.version = version
+	}
+}
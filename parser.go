@@ -0,0 +1,981 @@
+package kdlgo
+
+import (
+	"errors"
+	"io"
+	"strings"
+)
+
+func parseDocument(kdlr *kdlReader) (*Document, error) {
+	nodes, err := parseNodes(kdlr, false)
+	if err != nil {
+		err = wrapError(kdlr, err)
+		if !kdlr.options.recover {
+			return nil, err
+		}
+		var parseErr *ParseError
+		errors.As(err, &parseErr)
+		kdlr.errs = append(kdlr.errs, parseErr)
+	}
+
+	doc := NewDocument(nodes...)
+	doc.version = kdlr.options.version
+	if len(kdlr.errs) > 0 {
+		return doc, kdlr.errs
+	}
+	return doc, nil
+}
+
+func parseNodes(kdlr *kdlReader, inChildren bool) ([]*Node, error) {
+	var nodes []*Node
+	for {
+		err := skipLineSpace(kdlr)
+		if err != nil {
+			return nodes, recoverError(kdlr, err)
+		}
+
+		r, err := kdlr.peek()
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				if inChildren {
+					return nodes, recoverError(kdlr, unexpectedEOFErr())
+				}
+				return nodes, nil
+			}
+			return nodes, err
+		}
+
+		if r == closeBracket {
+			if inChildren {
+				kdlr.readRune()
+				return nodes, nil
+			}
+			err = recoverError(kdlr, invalidSyntaxErr())
+			if err != nil {
+				return nodes, err
+			}
+			kdlr.readRune()
+			continue
+		}
+
+		skipNext, _ := kdlr.isNext([]byte{slash, dash})
+		if skipNext {
+			err = skipSlashdashSpace(kdlr)
+			if err != nil {
+				err = recoverError(kdlr, err)
+				if err != nil {
+					return nodes, err
+				}
+			}
+		}
+
+		node, err := parseNode(kdlr)
+		if node != nil && !skipNext {
+			nodes = append(nodes, node)
+		}
+		if err != nil {
+			err = recoverError(kdlr, err)
+			if err != nil {
+				return nodes, err
+			}
+			err = skipToNodeEnd(kdlr)
+			if err != nil {
+				return nodes, err
+			}
+		}
+	}
+}
+
+// recoverError records err and returns nil when parsing in recovery mode so
+// that the caller can carry on, unless an error was already recorded at the
+// same offset. Otherwise, or if err can't be recovered from,
+// err is returned as is.
+func recoverError(kdlr *kdlReader, err error) error {
+	if !kdlr.options.recover {
+		return err
+	}
+
+	err = wrapError(kdlr, err)
+	var parseErr *ParseError
+	if !errors.As(err, &parseErr) || parseErr.Kind == KDLReadFailure {
+		return err
+	}
+	// A node that failed on a stray } stops right before it, so the } would
+	// otherwise be reported again.
+	if n := len(kdlr.errs); n > 0 && kdlr.errs[n-1].Offset == parseErr.Offset {
+		return nil
+	}
+	kdlr.errs = append(kdlr.errs, parseErr)
+	return nil
+}
+
+// skipToNodeEnd discards the rest of a node that failed to parse, up to and
+// including its terminator, so that parsing can resume at the next node.
+// Child blocks and strings are skipped as a whole.
+func skipToNodeEnd(kdlr *kdlReader) error {
+	depth := 0
+	for {
+		r, err := kdlr.peek()
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return err
+		}
+
+		switch {
+		case r == closeBracket && depth == 0:
+			return nil
+		case depth == 0 && (isNewline(r) || r == semicolon):
+			readNewline(kdlr)
+			return nil
+		case r == dquote:
+			kdlr.readRune()
+			readQuotedString(kdlr)
+			continue
+		case r == openBracket:
+			depth++
+		case r == closeBracket:
+			depth--
+		}
+
+		isComment, _ := kdlr.isNext([]byte{slash, slash})
+		if isComment {
+			err = skipLineComment(kdlr)
+			if err != nil || depth == 0 {
+				return err
+			}
+			continue
+		}
+
+		isBlock, _ := kdlr.isNext([]byte{slash, asterisk})
+		if isBlock {
+			skipBlockComment(kdlr)
+			continue
+		}
+
+		kdlr.readRune()
+	}
+}
+
+func parseNode(kdlr *kdlReader) (*Node, error) {
+	start := kdlr.position()
+	declaredType, err := parseTypeAnnotation(kdlr)
+	if err != nil {
+		return nil, err
+	}
+
+	typeEnd := kdlr.position()
+	if len(declaredType) > 0 && kdlr.isVersion2() {
+		_, err = skipNodeSpace(kdlr)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	nameStart := kdlr.position()
+	name, err := parseIdentifier(kdlr)
+	if err != nil {
+		return nil, err
+	}
+
+	node := NewNode(name)
+	node.nameSpan = Span{Start: nameStart, End: kdlr.position()}
+	node.span = Span{Start: start, End: node.nameSpan.End}
+	if len(declaredType) > 0 {
+		node.declaredType = declaredType
+		node.typeSpan = Span{Start: start, End: typeEnd}
+	}
+	hasChildren := false
+	hasActiveChildren := false
+	for {
+		hasSpace, err := skipNodeSpace(kdlr)
+		if err != nil {
+			return node, err
+		}
+		node.trailingEnd = kdlr.position()
+
+		r, err := kdlr.peek()
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				return node, nil
+			}
+			return node, err
+		}
+
+		if isNewline(r) || r == semicolon {
+			readNewline(kdlr)
+			return node, nil
+		}
+
+		if r == closeBracket {
+			return node, nil
+		}
+
+		isComment, _ := kdlr.isNext([]byte{slash, slash})
+		if isComment {
+			return node, skipLineComment(kdlr)
+		}
+
+		if hasChildren && !kdlr.isVersion2() {
+			return node, invalidSyntaxErr()
+		}
+
+		skipNext, _ := kdlr.isNext([]byte{slash, dash})
+		if skipNext {
+			err = skipSlashdashSpace(kdlr)
+			if err != nil {
+				return node, err
+			}
+			r, err = kdlr.peek()
+			if err != nil {
+				if errors.Is(err, io.EOF) {
+					return node, unexpectedEOFErr()
+				}
+				return node, err
+			}
+		}
+
+		// KDL 2.0 allows further slashdashed children blocks after the
+		// children, but nothing else.
+		if hasChildren && (r != openBracket || (hasActiveChildren && !skipNext)) {
+			return node, invalidSyntaxErr()
+		}
+
+		if r == openBracket {
+			childrenStart := kdlr.position()
+			kdlr.readRune()
+			children, err := parseNodes(kdlr, true)
+			if !skipNext {
+				node.children = children
+				node.span.End = kdlr.position()
+				node.childrenSpan = Span{Start: childrenStart, End: node.span.End}
+			}
+			if err != nil {
+				return node, err
+			}
+			hasChildren = true
+			hasActiveChildren = hasActiveChildren || !skipNext
+			continue
+		}
+
+		if !hasSpace {
+			return node, invalidSyntaxErr()
+		}
+
+		prop, value, err := parseEntry(kdlr)
+		if err != nil {
+			return node, err
+		}
+		if skipNext {
+			continue
+		}
+		if prop != nil {
+			node.props = append(node.props, *prop)
+		} else {
+			node.args = append(node.args, value)
+		}
+		node.span.End = kdlr.position()
+	}
+}
+
+// parseIdentifier reads a node name or property key, which may either be a
+// bare identifier or a (raw) string.
+func parseIdentifier(kdlr *kdlReader) (string, error) {
+	r, err := kdlr.peek()
+	if err != nil {
+		return "", err
+	}
+
+	if r == dquote || isRawStringStart(kdlr) {
+		value, err := parseStringValue(kdlr)
+		if err != nil {
+			return "", err
+		}
+		return value.ToString()
+	}
+
+	start := kdlr.position()
+	token, err := readBareToken(kdlr)
+	if err != nil {
+		return "", err
+	}
+	if len(token) < 1 {
+		return "", invalidSyntaxErr()
+	}
+	if !isValidIdentifier(kdlr, token) {
+		return "", wrapErrorAt(kdlr, start, invalidKeyCharErr())
+	}
+	return token, nil
+}
+
+// parseTypeAnnotation reads an optional `(type)` prefix, returning an empty
+// string if there is none.
+func parseTypeAnnotation(kdlr *kdlReader) (string, error) {
+	isAnnotated, _ := kdlr.isNext([]byte{openParenthesis})
+	if !isAnnotated {
+		return "", nil
+	}
+
+	if kdlr.isVersion2() {
+		_, err := skipNodeSpace(kdlr)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	declaredType, err := parseIdentifier(kdlr)
+	if err != nil {
+		if errors.Is(err, io.EOF) {
+			return "", unexpectedEOFErr()
+		}
+		return "", err
+	}
+
+	if kdlr.isVersion2() {
+		_, err = skipNodeSpace(kdlr)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	isClosed, _ := kdlr.isNext([]byte{closeParenthesis})
+	if !isClosed {
+		return "", invalidSyntaxErr()
+	}
+	return declaredType, nil
+}
+
+// parseEntry reads either an argument or a property. The returned property
+// is nil when the entry is an argument.
+func parseEntry(kdlr *kdlReader) (*Property, KDLValue, error) {
+	var value KDLValue
+	r, err := kdlr.peek()
+	if err != nil {
+		return nil, value, err
+	}
+
+	if r == openParenthesis {
+		value, err = parseTypedValue(kdlr)
+		return nil, value, err
+	}
+
+	start := kdlr.position()
+	var key string
+	if r == dquote || isRawStringStart(kdlr) {
+		value, err = parseStringValue(kdlr)
+		if err != nil {
+			return nil, value, err
+		}
+		value.span = Span{Start: start, End: kdlr.position()}
+		keySpan := value.span
+		if !isPropertyNext(kdlr) {
+			return nil, value, nil
+		}
+		key, err = value.ToString()
+		if err != nil {
+			return nil, value, err
+		}
+		return parseProperty(kdlr, key, keySpan)
+	}
+
+	if r == pound && kdlr.isVersion2() {
+		value, err = parseEntryValue(kdlr)
+		if err != nil {
+			return nil, value, err
+		}
+		value.span = Span{Start: start, End: kdlr.position()}
+		return nil, value, nil
+	}
+
+	token, err := readBareToken(kdlr)
+	if err != nil {
+		return nil, value, err
+	}
+	if len(token) < 1 {
+		return nil, value, invalidSyntaxErr()
+	}
+	keySpan := Span{Start: start, End: kdlr.position()}
+	if !isPropertyNext(kdlr) {
+		value, err = valueFromToken(kdlr, token)
+		if err != nil {
+			return nil, value, wrapErrorAt(kdlr, start, err)
+		}
+		value.span = keySpan
+		return nil, value, nil
+	}
+	if !isValidIdentifier(kdlr, token) {
+		return nil, value, wrapErrorAt(kdlr, start, invalidKeyCharErr())
+	}
+	key = token
+	return parseProperty(kdlr, key, keySpan)
+}
+
+// parseProperty reads the value of a property whose key and equals sign have
+// already been consumed.
+func parseProperty(kdlr *kdlReader, key string, keySpan Span) (*Property, KDLValue, error) {
+	if kdlr.isVersion2() {
+		_, err := skipNodeSpace(kdlr)
+		if err != nil {
+			return nil, KDLValue{}, err
+		}
+	}
+
+	start := keySpan.Start
+	value, err := parseTypedValue(kdlr)
+	if err != nil {
+		return nil, value, err
+	}
+	prop := NewProperty(key, value)
+	prop.span = Span{Start: start, End: kdlr.position()}
+	prop.keySpan = keySpan
+	return &prop, value, nil
+}
+
+// parseTypedValue reads a value with an optional type annotation.
+func parseTypedValue(kdlr *kdlReader) (KDLValue, error) {
+	start := kdlr.position()
+	declaredType, err := parseTypeAnnotation(kdlr)
+	if err != nil {
+		return KDLValue{}, err
+	}
+	typeEnd := kdlr.position()
+	if len(declaredType) > 0 && kdlr.isVersion2() {
+		_, err = skipNodeSpace(kdlr)
+		if err != nil {
+			return KDLValue{}, err
+		}
+	}
+
+	value, err := parseEntryValue(kdlr)
+	if err != nil {
+		return value, err
+	}
+	value.span = Span{Start: start, End: kdlr.position()}
+	if len(declaredType) > 0 {
+		value.declaredType = declaredType
+		value.typeSpan = Span{Start: start, End: typeEnd}
+	}
+	return value, nil
+}
+
+func parseEntryValue(kdlr *kdlReader) (KDLValue, error) {
+	var value KDLValue
+	r, err := kdlr.peek()
+	if err != nil {
+		if errors.Is(err, io.EOF) {
+			return value, unexpectedEOFErr()
+		}
+		return value, err
+	}
+
+	if r == dquote || isRawStringStart(kdlr) {
+		return parseStringValue(kdlr)
+	}
+
+	start := kdlr.position()
+	if r == pound && kdlr.isVersion2() {
+		kdlr.readRune()
+		token, err := readBareToken(kdlr)
+		if err != nil {
+			return value, err
+		}
+		value, err = keywordValue(token)
+		if err != nil {
+			return value, wrapErrorAt(kdlr, start, err)
+		}
+		return value, nil
+	}
+
+	token, err := readBareToken(kdlr)
+	if err != nil {
+		return value, err
+	}
+	if len(token) < 1 {
+		return value, invalidSyntaxErr()
+	}
+	value, err = valueFromToken(kdlr, token)
+	if err != nil {
+		return value, wrapErrorAt(kdlr, start, err)
+	}
+	return value, nil
+}
+
+func parseStringValue(kdlr *kdlReader) (KDLValue, error) {
+	var value KDLValue
+	r, err := kdlr.readRune()
+	if err != nil {
+		return value, err
+	}
+
+	if (r == 'r' && !kdlr.isVersion2()) || (r == pound && kdlr.isVersion2()) {
+		hashes := 0
+		if r == pound {
+			hashes = 1
+		}
+		s, err := readRawString(kdlr, hashes)
+		if err != nil {
+			return value, err
+		}
+		return NewKDLRawString("", s).GetValue(), nil
+	}
+
+	s, err := readQuotedString(kdlr)
+	if err != nil {
+		return value, err
+	}
+	return KDLValue{String: s, Type: KDLStringType}, nil
+}
+
+// readQuotedString reads the remainder of a string whose opening quote has
+// already been consumed and returns its unescaped content.
+func readQuotedString(kdlr *kdlReader) (string, error) {
+	if kdlr.isVersion2() {
+		isMultiLine, _ := kdlr.isNext([]byte{dquote, dquote})
+		if isMultiLine {
+			return readMultiLineString(kdlr, "", false)
+		}
+	}
+
+	var s strings.Builder
+	for {
+		r, err := kdlr.readRune()
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				return "", unexpectedEOFErr()
+			}
+			return "", err
+		}
+
+		if r == dquote {
+			if kdlr.isVersion2() {
+				return unescapeStringV2(s.String())
+			}
+			return unescapeString(s.String())
+		}
+		if isNewline(r) && kdlr.isVersion2() {
+			return "", invalidSyntaxErr()
+		}
+
+		s.WriteRune(r)
+		if r == backslash {
+			r, err = kdlr.readRune()
+			if err != nil {
+				if errors.Is(err, io.EOF) {
+					return "", unexpectedEOFErr()
+				}
+				return "", err
+			}
+			s.WriteRune(r)
+		}
+	}
+}
+
+// readRawString reads a raw string whose leading 'r', or in KDL 2.0 the
+// first of its leading '#', has already been consumed. count is the number of
+// '#' consumed.
+func readRawString(kdlr *kdlReader, count int) (string, error) {
+	for {
+		r, err := kdlr.readRune()
+		if err != nil {
+			return "", unexpectedEOFErr()
+		}
+		if r == dquote {
+			break
+		}
+		if r != pound {
+			return "", invalidSyntaxErr()
+		}
+		count++
+	}
+
+	hashes := strings.Repeat(string(pound), count)
+	if kdlr.isVersion2() {
+		isMultiLine, _ := kdlr.isNext([]byte{dquote, dquote})
+		if isMultiLine {
+			return readMultiLineString(kdlr, hashes, true)
+		}
+	}
+
+	closing := []byte(hashes)
+	var s strings.Builder
+	for {
+		r, err := kdlr.readRune()
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				return "", unexpectedEOFErr()
+			}
+			return "", err
+		}
+
+		if r == dquote {
+			if count == 0 {
+				return s.String(), nil
+			}
+			isEnd, _ := kdlr.isNext(closing)
+			if isEnd {
+				return s.String(), nil
+			}
+		}
+		if isNewline(r) && kdlr.isVersion2() {
+			return "", invalidSyntaxErr()
+		}
+		s.WriteRune(r)
+	}
+}
+
+// readMultiLineString reads a KDL 2.0 multi-line string whose opening quotes
+// have already been consumed, up to and including its closing quotes and
+// hashes. Escapes are only interpreted if the string isn't raw.
+func readMultiLineString(kdlr *kdlReader, hashes string, raw bool) (string, error) {
+	r, err := kdlr.peek()
+	if err != nil {
+		if errors.Is(err, io.EOF) {
+			return "", unexpectedEOFErr()
+		}
+		return "", err
+	}
+	if !isNewline(r) {
+		return "", invalidSyntaxErr()
+	}
+
+	closing := []byte(`"""` + hashes)
+	var body strings.Builder
+	for {
+		isEnd, _ := kdlr.isNext(closing)
+		if isEnd {
+			break
+		}
+
+		r, err = kdlr.readRune()
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				return "", unexpectedEOFErr()
+			}
+			return "", err
+		}
+		body.WriteRune(r)
+		if r == backslash && !raw {
+			r, err = kdlr.readRune()
+			if err != nil {
+				if errors.Is(err, io.EOF) {
+					return "", unexpectedEOFErr()
+				}
+				return "", err
+			}
+			body.WriteRune(r)
+		}
+	}
+
+	s, err := dedentMultiLineString(body.String())
+	if err != nil || raw {
+		return s, err
+	}
+	return unescapeStringV2(s)
+}
+
+func isRawStringStart(kdlr *kdlReader) bool {
+	var prefix byte = 'r'
+	if kdlr.isVersion2() {
+		prefix = pound
+	}
+
+	length := 1
+	for {
+		length++
+		bytes, err := kdlr.peekX(length)
+		if err != nil || bytes[0] != prefix {
+			return false
+		}
+
+		switch bytes[length-1] {
+		case dquote:
+			return true
+		case pound:
+			continue
+		default:
+			return false
+		}
+	}
+}
+
+// readBareToken reads everything up to the next character that cannot be
+// part of a bare identifier, number or keyword.
+func readBareToken(kdlr *kdlReader) (string, error) {
+	delimiters := nonIdentifierChars
+	if kdlr.isVersion2() {
+		delimiters = nonIdentifierCharsV2
+	}
+
+	var s strings.Builder
+	for {
+		r, err := kdlr.peek()
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				return s.String(), nil
+			}
+			return "", err
+		}
+
+		if isWhitespace(r) || isNewline(r) || strings.ContainsRune(delimiters, r) {
+			return s.String(), nil
+		}
+		kdlr.readRune()
+		s.WriteRune(r)
+	}
+}
+
+func valueFromToken(kdlr *kdlReader, token string) (KDLValue, error) {
+	if kdlr.isVersion2() {
+		if isNumberToken(token) {
+			return parseNumberString(token)
+		}
+		if !isValidIdentifierV2(token) {
+			return KDLValue{}, invalidSyntaxErr()
+		}
+		return KDLValue{String: token, Type: KDLStringType}, nil
+	}
+
+	switch token {
+	case "true":
+		return NewKDLBool("", true).GetValue(), nil
+	case "false":
+		return NewKDLBool("", false).GetValue(), nil
+	case "null":
+		return NewKDLNull("").GetValue(), nil
+	}
+
+	if !isNumberToken(token) {
+		return KDLValue{}, invalidSyntaxErr()
+	}
+
+	return parseNumberString(token)
+}
+
+func isNumberToken(token string) bool {
+	if len(token) > 1 && (token[0] == '+' || token[0] == dash) {
+		token = token[1:]
+	}
+	return len(token) > 0 && token[0] >= '0' && token[0] <= '9'
+}
+
+func isValidIdentifier(kdlr *kdlReader, token string) bool {
+	if kdlr.isVersion2() {
+		return isValidIdentifierV2(token)
+	}
+
+	switch token {
+	case "", "true", "false", "null":
+		return false
+	}
+	return !isNumberToken(token)
+}
+
+func skipLineSpace(kdlr *kdlReader) error {
+	for {
+		r, err := kdlr.peek()
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return err
+		}
+
+		if isWhitespace(r) || isNewline(r) {
+			kdlr.readRune()
+			continue
+		}
+
+		if r == backslash && kdlr.isVersion2() {
+			kdlr.readRune()
+			err = skipEscline(kdlr)
+			if err != nil {
+				return err
+			}
+			continue
+		}
+
+		isComment, _ := kdlr.isNext([]byte{slash, slash})
+		if isComment {
+			err = skipLineComment(kdlr)
+			if err != nil {
+				return err
+			}
+			continue
+		}
+
+		isBlock, _ := kdlr.isNext([]byte{slash, asterisk})
+		if isBlock {
+			err = skipBlockComment(kdlr)
+			if err != nil {
+				return err
+			}
+			continue
+		}
+
+		return nil
+	}
+}
+
+// skipNodeSpace discards whitespace, block comments and line continuations
+// between the parts of a node, reporting whether anything was discarded.
+func skipNodeSpace(kdlr *kdlReader) (bool, error) {
+	hasSpace := false
+	for {
+		r, err := kdlr.peek()
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				return hasSpace, nil
+			}
+			return hasSpace, err
+		}
+
+		if isWhitespace(r) {
+			kdlr.readRune()
+			hasSpace = true
+			continue
+		}
+
+		if r == backslash {
+			kdlr.readRune()
+			err = skipEscline(kdlr)
+			if err != nil {
+				return hasSpace, err
+			}
+			hasSpace = true
+			continue
+		}
+
+		isBlock, _ := kdlr.isNext([]byte{slash, asterisk})
+		if isBlock {
+			err = skipBlockComment(kdlr)
+			if err != nil {
+				return hasSpace, err
+			}
+			hasSpace = true
+			continue
+		}
+
+		return hasSpace, nil
+	}
+}
+
+// skipEscline discards the rest of a line continuation whose leading
+// backslash has already been consumed.
+func skipEscline(kdlr *kdlReader) error {
+	for {
+		r, err := kdlr.peek()
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				if kdlr.isVersion2() {
+					return nil
+				}
+				return unexpectedEOFErr()
+			}
+			return err
+		}
+
+		if isWhitespace(r) {
+			kdlr.readRune()
+			continue
+		}
+
+		if isNewline(r) {
+			readNewline(kdlr)
+			return nil
+		}
+
+		isComment, _ := kdlr.isNext([]byte{slash, slash})
+		if isComment {
+			return skipLineComment(kdlr)
+		}
+
+		isBlock, _ := kdlr.isNext([]byte{slash, asterisk})
+		if isBlock {
+			err = skipBlockComment(kdlr)
+			if err != nil {
+				return err
+			}
+			continue
+		}
+
+		return invalidSyntaxErr()
+	}
+}
+
+// skipLineComment discards a line comment, including its trailing newline,
+// whose leading slashes have already been consumed.
+func skipLineComment(kdlr *kdlReader) error {
+	for {
+		r, err := kdlr.peek()
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return err
+		}
+
+		if isNewline(r) {
+			readNewline(kdlr)
+			return nil
+		}
+		kdlr.readRune()
+	}
+}
+
+// skipBlockComment discards a (possibly nested) block comment whose opening
+// delimiter has already been consumed.
+func skipBlockComment(kdlr *kdlReader) error {
+	count := 1
+	for count > 0 {
+		isOpen, _ := kdlr.isNext([]byte{slash, asterisk})
+		if isOpen {
+			count++
+			continue
+		}
+
+		isClose, _ := kdlr.isNext([]byte{asterisk, slash})
+		if isClose {
+			count--
+			continue
+		}
+
+		_, err := kdlr.readRune()
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				return unexpectedEOFErr()
+			}
+			return err
+		}
+	}
+	return nil
+}
+
+// readNewline consumes a single newline, treating CRLF as one.
+func readNewline(kdlr *kdlReader) {
+	r, _ := kdlr.readRune()
+	if r == '\r' {
+		kdlr.isNext([]byte{newline})
+	}
+}
+
+const (
+	nonIdentifierChars   = "\\/(){}<>;[]=,\""
+	nonIdentifierCharsV2 = "\\/(){};[]=\"#"
+)
+
+func isNewline(r rune) bool {
+	switch r {
+	case '\r', '\n', '\u0085', '\u000B', '\u000C', '\u2028', '\u2029':
+		return true
+	}
+	return false
+}
+
+func isWhitespace(r rune) bool {
+	switch r {
+	case '\t', ' ', '\u00A0', '\u1680', '\u202F', '\u205F', '\u3000', '\uFEFF':
+		return true
+	}
+	return r >= '\u2000' && r <= '\u200A'
+}
@@ -11,7 +11,7 @@ func TestParseFromFile(t *testing.T) {
 		t.Fatal(err)
 	}
 	expected := []string{
-		`firstkey "first\n\ttab\nnewline\"\nval" "testing\""`,
+		`firstkey "first\n\ttab\nnewline\"\nval" r#"testing""#`,
 		`numbers 543 234 85720394`,
 		`thirdkey true null`,
 		`secondkey 12 "test" null false "testagain"`,
@@ -26,7 +26,7 @@ func TestParseFromFile(t *testing.T) {
 		`"quoted node for numbers" 21 43 465 "string"`,
 		`smile "😁"`,
 		`!@#$@$%Q#$%~@!40 "1.2.3" { !!!!! true; }`,
-		`foo123~!@#$%^&*.:'|/?+ "weeee"`,
+		`"foo123~!@#$%^&*.:'|/?+" "weeee"`,
 		`ノード { お名前 "☜(ﾟヮﾟ☜)"; }`,
 		`foo { bar true; } "baz" { quux false; } 1 2 3`,
 		`key "value"`,
@@ -54,3 +54,19 @@ func TestParseFromFile(t *testing.T) {
 		}
 	}
 }
+
+func TestKDLObjectsLookups(t *testing.T) {
+	objs, err := ParseFile("tests/test_cases/input/same_name_nodes.kdl")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if all := objs.All("node"); len(all) != 2 {
+		t.Error("Expected 2 objects named 'node' but got " + strconv.Itoa(len(all)))
+	}
+	if !objs.Has("node") || objs.First("node") == nil {
+		t.Error("Expected to find an object named 'node'")
+	}
+	if objs.Has("missing") || objs.First("missing") != nil {
+		t.Error("Expected no object named 'missing'")
+	}
+}
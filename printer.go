@@ -0,0 +1,239 @@
+package kdlgo
+
+import (
+	"io"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// FormatOptions configures how documents and nodes are formatted.
+type FormatOptions struct {
+	// Indent is the number of spaces, or tabs if UseTabs is set, children are
+	// indented by. It defaults to 4 spaces or 1 tab.
+	Indent  int
+	UseTabs bool
+
+	// MaxWidth is the width, in characters, after which the entries of a node
+	// are wrapped onto the next line with a `\` line continuation. Lines are
+	// never wrapped if it is 0.
+	MaxWidth int
+
+	// PropsFirst prints properties before arguments instead of after them.
+	PropsFirst bool
+	// SortProps prints properties sorted by key instead of in the order they
+	// were declared.
+	SortProps bool
+	// DedupeProps only prints the last of any repeated property, which is the
+	// one that takes effect.
+	DedupeProps bool
+
+	// RawStringsAsStrings prints raw strings as regular, escaped strings.
+	RawStringsAsStrings bool
+}
+
+func (opts FormatOptions) indent(depth int) string {
+	unit := "    "
+	switch {
+	case opts.UseTabs && opts.Indent > 0:
+		unit = strings.Repeat("\t", opts.Indent)
+	case opts.UseTabs:
+		unit = "\t"
+	case opts.Indent > 0:
+		unit = strings.Repeat(" ", opts.Indent)
+	}
+	return strings.Repeat(unit, depth)
+}
+
+// Format prints the document with every node on its own line and children
+// indented below their parent. Documents parsed as KDL 2.0 are printed as KDL
+// 2.0 and any other as KDL 1.0, which can't hold infinite or NaN numbers.
+func (doc *Document) Format(opts FormatOptions) (string, error) {
+	var s strings.Builder
+	p := &printer{w: &s, opts: opts, version: doc.version}
+	for _, node := range doc.nodes {
+		err := p.printNode(node, 0)
+		if err != nil {
+			return "", err
+		}
+	}
+	return s.String(), nil
+}
+
+// Format prints the node and its children like Document.Format, including the
+// trailing newline.
+func (node *Node) Format(opts FormatOptions) (string, error) {
+	var s strings.Builder
+	p := &printer{w: &s, opts: opts}
+	err := p.printNode(node, 0)
+	if err != nil {
+		return "", err
+	}
+	return s.String(), nil
+}
+
+type printer struct {
+	w    io.Writer
+	opts FormatOptions
+
+	// version is the version of KDL to print, which is KDL 1.0 unless it is
+	// KDLVersion2.
+	version KDLVersion
+}
+
+func (p *printer) write(s string) error {
+	_, err := io.WriteString(p.w, s)
+	return err
+}
+
+func (p *printer) printNode(node *Node, depth int) error {
+	indent := p.opts.indent(depth)
+	head := p.key(node.name)
+	if len(node.declaredType) > 0 {
+		head = "(" + p.key(node.declaredType) + ")" + head
+	}
+
+	entries, err := p.entries(node)
+	if err != nil {
+		return err
+	}
+
+	line := indent + head
+	width := utf8.RuneCountInString(line)
+	continuation := indent + p.opts.indent(1)
+	for i, entry := range entries {
+		entryWidth := utf8.RuneCountInString(entry)
+		// Leave room for the ` \` unless this is the last entry.
+		reserved := 2
+		if i == len(entries)-1 {
+			reserved = 0
+		}
+		if p.opts.MaxWidth > 0 && width+1+entryWidth+reserved > p.opts.MaxWidth {
+			err = p.write(line + " \\\n")
+			if err != nil {
+				return err
+			}
+			line = continuation + entry
+			width = utf8.RuneCountInString(line)
+			continue
+		}
+		line += " " + entry
+		width += 1 + entryWidth
+	}
+
+	if len(node.children) == 0 {
+		return p.write(line + "\n")
+	}
+
+	err = p.write(line + " {\n")
+	if err != nil {
+		return err
+	}
+	for _, child := range node.children {
+		err = p.printNode(child, depth+1)
+		if err != nil {
+			return err
+		}
+	}
+	return p.write(indent + "}\n")
+}
+
+func (p *printer) printObject(obj KDLObject, depth int) error {
+	indent := p.opts.indent(depth)
+	value := obj.GetValue()
+	if value.Type != KDLObjectsType || len(value.Objects) == 0 {
+		s, err := RecreateKDLObj(obj)
+		if err != nil {
+			return err
+		}
+		return p.write(indent + s + "\n")
+	}
+
+	err := p.write(indent + recreateKey(obj.GetKey()) + " {\n")
+	if err != nil {
+		return err
+	}
+	for _, child := range value.Objects {
+		err = p.printObject(child, depth+1)
+		if err != nil {
+			return err
+		}
+	}
+	return p.write(indent + "}\n")
+}
+
+// entries returns the arguments and properties of the node, formatted and in
+// the order they should be printed.
+func (p *printer) entries(node *Node) ([]string, error) {
+	var args, props []string
+	for _, arg := range node.args {
+		s, err := p.recreate(p.value(arg))
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, s)
+	}
+
+	nodeProps := node.props
+	if p.opts.DedupeProps {
+		nodeProps = dedupeProps(nodeProps)
+	}
+	if p.opts.SortProps {
+		nodeProps = append([]Property(nil), nodeProps...)
+		sort.SliceStable(nodeProps, func(i, j int) bool {
+			return nodeProps[i].key < nodeProps[j].key
+		})
+	}
+	for _, prop := range nodeProps {
+		s, err := p.recreate(p.value(prop.value))
+		if err != nil {
+			return nil, err
+		}
+		props = append(props, p.key(prop.key)+"="+s)
+	}
+
+	if p.opts.PropsFirst {
+		return append(props, args...), nil
+	}
+	return append(args, props...), nil
+}
+
+// value returns the value as it should be printed.
+func (p *printer) value(value KDLValue) KDLValue {
+	if p.opts.RawStringsAsStrings && value.Type == KDLRawStringType {
+		value.String = value.RawString
+		value.Type = KDLStringType
+	}
+	return value
+}
+
+func (p *printer) key(key string) string {
+	if p.version == KDLVersion2 {
+		return recreateKeyV2(key)
+	}
+	return recreateKey(key)
+}
+
+func (p *printer) recreate(value KDLValue) (string, error) {
+	if p.version == KDLVersion2 {
+		return recreateKDLV2(value)
+	}
+	return value.RecreateKDL()
+}
+
+// dedupeProps keeps only the last occurrence of every key, in the position it
+// was last declared in.
+func dedupeProps(props []Property) []Property {
+	last := map[string]int{}
+	for i, prop := range props {
+		last[prop.key] = i
+	}
+
+	var deduped []Property
+	for i, prop := range props {
+		if last[prop.key] == i {
+			deduped = append(deduped, prop)
+		}
+	}
+	return deduped
+}
@@ -0,0 +1,107 @@
+package kdlgo
+
+import (
+	"strings"
+	"testing"
+)
+
+const formatInput = `parent "arg" b=2 a=1 b=3 {
+    child1 12
+    child2 key="value" { grandchild; }
+    empty {}
+}
+`
+
+func TestFormat(t *testing.T) {
+	doc, err := ParseDocumentString(formatInput)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		opts     FormatOptions
+		expected string
+	}{
+		{FormatOptions{}, `parent "arg" b=2 a=1 b=3 {
+    child1 12
+    child2 key="value" {
+        grandchild
+    }
+    empty
+}
+`},
+		{FormatOptions{Indent: 2, SortProps: true, DedupeProps: true}, `parent "arg" a=1 b=3 {
+  child1 12
+  child2 key="value" {
+    grandchild
+  }
+  empty
+}
+`},
+		{FormatOptions{UseTabs: true, PropsFirst: true, DedupeProps: true}, "parent a=1 b=3 \"arg\" {\n\tchild1 12\n\tchild2 key=\"value\" {\n\t\tgrandchild\n\t}\n\tempty\n}\n"},
+	}
+	for _, test := range tests {
+		s, err := doc.Format(test.opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if s != test.expected {
+			t.Errorf("Expected:\n%s\nGot:\n%s", test.expected, s)
+		}
+	}
+}
+
+func TestFormatMaxWidth(t *testing.T) {
+	doc, err := ParseDocumentString(`node "first argument" "second argument" "third" key="a property value" {
+    child "another long argument" "and another one"
+}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s, err := doc.Format(FormatOptions{MaxWidth: 40})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `node "first argument" \
+    "second argument" "third" \
+    key="a property value" {
+    child "another long argument" \
+        "and another one"
+}
+`
+	if s != expected {
+		t.Errorf("Expected:\n%s\nGot:\n%s", expected, s)
+	}
+	for _, line := range strings.Split(s, "\n") {
+		if len(line) > 40 {
+			t.Errorf("Line is longer than 40 characters: %q", line)
+		}
+	}
+
+	reparsed, err := ParseDocumentString(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	original, _ := doc.RecreateKDL()
+	roundTrip, _ := reparsed.RecreateKDL()
+	if original != roundTrip {
+		t.Errorf("Wrapped document doesn't parse back to the original:\n%s\n%s", original, roundTrip)
+	}
+}
+
+func TestFormatNode(t *testing.T) {
+	node := NewNode("node")
+	node.AddArg(NewKDLInt("", 1).GetValue())
+	child := NewNode("child")
+	child.SetDeclaredType("type")
+	node.AddChild(child)
+
+	s, err := node.Format(FormatOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s != "node 1 {\n    (type)child\n}\n" {
+		t.Errorf("Node is incorrectly formatted: %q", s)
+	}
+}
@@ -0,0 +1,637 @@
+package kdlgo
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// CompiledQuery is a KDL Query Language (KQL) query that can be run against
+// any number of documents. It is safe for concurrent use.
+//
+// A query is made of selectors separated by "||", whose results are all
+// returned. Each selector is a list of matchers joined by combinators:
+//
+//	a b      b is a descendant of a
+//	a > b    b is a child of a
+//	a + b    b comes right after its sibling a
+//	a ~ b    b comes anywhere after its sibling a
+//
+// A selector can start with `top() >` to only match top-level nodes, and
+// `top()` on its own selects every top-level node. A matcher is an optional
+// type annotation, with `()` matching any, an optional node name and any
+// number of filters in brackets:
+//
+//	[]                    any node
+//	[val()] [val(1)]      the node has a first, or second, argument
+//	[prop(key)] [key]     the node has the property key
+//	[name()] [tag()]      the node's name, or type annotation
+//	[values()] [props()]  the node has any arguments, or properties
+//	[val() > 5]           the argument compares to the value
+//
+// The comparisons are = and != for any value, >, <, >= and <= for numbers and
+// ^= (starts with), $= (ends with) and *= (contains) for strings. Values are
+// written as in KDL, e.g. `[name = "web"]`, and `(type)` alone compares to a
+// tag() type annotation.
+type CompiledQuery struct {
+	query     string
+	selectors []querySelector
+}
+
+type querySelector struct {
+	matchers []queryMatcher
+	// combinators[i] joins matchers[i] and matchers[i+1].
+	combinators []byte
+}
+
+type queryMatcher struct {
+	isTop    bool
+	hasType  bool
+	typ      string
+	hasName  bool
+	name     string
+	filters  []queryFilter
+	position int
+}
+
+type queryFilter struct {
+	accessor queryAccessor
+	op       string
+	value    KDLValue
+}
+
+type queryAccessor struct {
+	kind  string
+	index int
+	key   string
+}
+
+const (
+	accessAny    = ""
+	accessVal    = "val"
+	accessProp   = "prop"
+	accessName   = "name"
+	accessTag    = "tag"
+	accessValues = "values"
+	accessProps  = "props"
+)
+
+// Query runs a KQL query against the document, returning the matching nodes
+// in the order they appear in. Use CompileQuery to run the same query more
+// than once.
+func Query(doc *Document, query string) ([]*Node, error) {
+	q, err := CompileQuery(query)
+	if err != nil {
+		return nil, err
+	}
+	return q.Find(doc), nil
+}
+
+// CompileQuery parses a KQL query. The error is a *ParseError with the
+// position of the error in the query.
+func CompileQuery(query string) (*CompiledQuery, error) {
+	p := &queryParser{query: query}
+	q := &CompiledQuery{query: query}
+	for {
+		sel, err := p.selector()
+		if err != nil {
+			return nil, err
+		}
+		q.selectors = append(q.selectors, sel)
+		p.skipSpace()
+		if p.eof() {
+			return q, nil
+		}
+		if !p.consume("||") {
+			return nil, p.err("expected || or the end of the query")
+		}
+	}
+}
+
+// MustCompileQuery is like CompileQuery but panics if the query is invalid.
+func MustCompileQuery(query string) *CompiledQuery {
+	q, err := CompileQuery(query)
+	if err != nil {
+		panic(err)
+	}
+	return q
+}
+
+func (q *CompiledQuery) String() string {
+	return q.query
+}
+
+// Find returns the nodes in the document matching the query in the order
+// they appear in.
+func (q *CompiledQuery) Find(doc *Document) []*Node {
+	var matches []*Node
+	q.find(&queryContext{}, doc.nodes, &matches)
+	return matches
+}
+
+// queryContext is where a node is in the document. The context of the
+// document itself, which top() matches, has no node.
+type queryContext struct {
+	node     *Node
+	parent   *queryContext
+	siblings []*Node
+	index    int
+}
+
+func (ctx *queryContext) sibling(index int) *queryContext {
+	return &queryContext{node: ctx.siblings[index], parent: ctx.parent, siblings: ctx.siblings, index: index}
+}
+
+func (q *CompiledQuery) find(parent *queryContext, nodes []*Node, matches *[]*Node) {
+	for i, node := range nodes {
+		ctx := &queryContext{node: node, parent: parent, siblings: nodes, index: i}
+		for _, sel := range q.selectors {
+			if sel.matchAt(len(sel.matchers)-1, ctx) {
+				*matches = append(*matches, node)
+				break
+			}
+		}
+		q.find(ctx, node.children, matches)
+	}
+}
+
+// matchAt reports whether the matchers up to i match with the last of them
+// matching ctx.
+func (sel querySelector) matchAt(i int, ctx *queryContext) bool {
+	if !sel.matchers[i].matches(ctx) {
+		return false
+	}
+	if i == 0 {
+		return true
+	}
+
+	switch sel.combinators[i-1] {
+	case '>':
+		return ctx.parent != nil && sel.matchAt(i-1, ctx.parent)
+	case '+':
+		return ctx.index > 0 && sel.matchAt(i-1, ctx.sibling(ctx.index-1))
+	case '~':
+		for j := ctx.index - 1; j >= 0; j-- {
+			if sel.matchAt(i-1, ctx.sibling(j)) {
+				return true
+			}
+		}
+		return false
+	}
+	for ancestor := ctx.parent; ancestor != nil; ancestor = ancestor.parent {
+		if sel.matchAt(i-1, ancestor) {
+			return true
+		}
+	}
+	return false
+}
+
+func (m queryMatcher) matches(ctx *queryContext) bool {
+	if m.isTop || ctx.node == nil {
+		return m.isTop && ctx.node == nil
+	}
+
+	node := ctx.node
+	switch {
+	case m.hasType && len(m.typ) == 0 && len(node.declaredType) == 0,
+		m.hasType && len(m.typ) > 0 && node.declaredType != m.typ,
+		m.hasName && node.name != m.name:
+		return false
+	}
+	for _, filter := range m.filters {
+		if !filter.matches(node) {
+			return false
+		}
+	}
+	return true
+}
+
+func (filter queryFilter) matches(node *Node) bool {
+	acc := filter.accessor
+	var value KDLValue
+	switch acc.kind {
+	case accessAny:
+		return true
+	case accessValues:
+		return len(node.args) > 0
+	case accessProps:
+		return len(node.props) > 0
+	case accessName:
+		value = KDLValue{String: node.name, Type: KDLStringType}
+	case accessTag:
+		if len(node.declaredType) == 0 {
+			return false
+		}
+		value = KDLValue{String: node.declaredType, Type: KDLStringType}
+	case accessVal:
+		if acc.index >= len(node.args) {
+			return false
+		}
+		value = node.args[acc.index]
+	case accessProp:
+		var ok bool
+		value, ok = node.GetProp(acc.key)
+		if !ok {
+			return false
+		}
+	}
+	if len(filter.op) == 0 {
+		return true
+	}
+	return compareQueryValues(value, filter.op, filter.value)
+}
+
+func compareQueryValues(value KDLValue, op string, expected KDLValue) bool {
+	if len(expected.declaredType) > 0 && expected.declaredType != value.declaredType {
+		return false
+	}
+
+	switch op {
+	case "=":
+		return queryValuesEqual(value, expected)
+	case "!=":
+		return !queryValuesEqual(value, expected)
+	case "^=", "$=", "*=":
+		s, ok := queryString(value)
+		substr, isString := queryString(expected)
+		if !ok || !isString {
+			return false
+		}
+		switch op {
+		case "^=":
+			return strings.HasPrefix(s, substr)
+		case "$=":
+			return strings.HasSuffix(s, substr)
+		}
+		return strings.Contains(s, substr)
+	}
+
+	cmp, ok := compareQueryNumbers(value, expected)
+	if !ok {
+		return false
+	}
+	switch op {
+	case ">":
+		return cmp > 0
+	case "<":
+		return cmp < 0
+	case ">=":
+		return cmp >= 0
+	}
+	return cmp <= 0
+}
+
+func queryValuesEqual(a KDLValue, b KDLValue) bool {
+	if s, ok := queryString(a); ok {
+		t, isString := queryString(b)
+		return isString && s == t
+	}
+	switch {
+	case a.Type != b.Type:
+		return false
+	case a.Type == KDLBoolType:
+		return a.Bool == b.Bool
+	case a.Type == KDLNumberType:
+		cmp, ok := compareQueryNumbers(a, b)
+		return ok && cmp == 0
+	}
+	return a.Type == KDLNullType
+}
+
+func queryString(value KDLValue) (string, bool) {
+	if value.Type != KDLStringType && value.Type != KDLRawStringType {
+		return "", false
+	}
+	s, _ := value.ToString()
+	return s, true
+}
+
+// compareQueryNumbers compares two numbers exactly if they are finite, and
+// returns false if either isn't a number or is NaN.
+func compareQueryNumbers(a KDLValue, b KDLValue) (int, bool) {
+	if a.Type != KDLNumberType || b.Type != KDLNumberType || a.nan || b.nan {
+		return 0, false
+	}
+	x, isFinite := a.BigRat()
+	y, bothFinite := b.BigRat()
+	if isFinite && bothFinite {
+		return x.Cmp(y), true
+	}
+	return a.Number.Cmp(&b.Number), true
+}
+
+type queryParser struct {
+	query string
+	pos   int
+}
+
+func (p *queryParser) eof() bool {
+	return p.pos >= len(p.query)
+}
+
+func (p *queryParser) peek(s string) bool {
+	return strings.HasPrefix(p.query[p.pos:], s)
+}
+
+func (p *queryParser) consume(s string) bool {
+	if !p.peek(s) {
+		return false
+	}
+	p.pos += len(s)
+	return true
+}
+
+// skipSpace skips any whitespace, reporting whether there was any.
+func (p *queryParser) skipSpace() bool {
+	start := p.pos
+	for !p.eof() {
+		r, size := utf8.DecodeRuneInString(p.query[p.pos:])
+		if !unicode.IsSpace(r) {
+			break
+		}
+		p.pos += size
+	}
+	return p.pos > start
+}
+
+func (p *queryParser) err(reason string) error {
+	return p.errAt(p.pos, reason)
+}
+
+func (p *queryParser) errAt(pos int, reason string) error {
+	return &ParseError{
+		Kind:    KDLInvalidQuery,
+		Line:    1,
+		Column:  utf8.RuneCountInString(p.query[:pos]) + 1,
+		Offset:  pos,
+		Snippet: p.query,
+		Err:     errors.New(reason),
+	}
+}
+
+func (p *queryParser) selector() (querySelector, error) {
+	var sel querySelector
+	p.skipSpace()
+	if p.consume("top()") {
+		sel.matchers = append(sel.matchers, queryMatcher{isTop: true})
+	} else {
+		m, err := p.matcher()
+		if err != nil {
+			return sel, err
+		}
+		sel.matchers = append(sel.matchers, m)
+	}
+
+	for {
+		start := p.pos
+		hasSpace := p.skipSpace()
+		if p.eof() || p.peek("||") {
+			p.pos = start
+			break
+		}
+		combinator := byte(' ')
+		switch c := p.query[p.pos]; c {
+		case '>', '+', '~':
+			combinator = c
+			p.pos++
+			p.skipSpace()
+		default:
+			if !hasSpace {
+				return sel, p.err("expected a combinator")
+			}
+		}
+		m, err := p.matcher()
+		if err != nil {
+			return sel, err
+		}
+		sel.combinators = append(sel.combinators, combinator)
+		sel.matchers = append(sel.matchers, m)
+	}
+
+	if len(sel.matchers) == 1 && sel.matchers[0].isTop {
+		sel.combinators = append(sel.combinators, '>')
+		sel.matchers = append(sel.matchers, queryMatcher{})
+	}
+	return sel, nil
+}
+
+func (p *queryParser) matcher() (queryMatcher, error) {
+	m := queryMatcher{position: p.pos}
+	if p.peek("top()") {
+		return m, p.err("top() can only start a selector")
+	}
+	if p.consume("(") {
+		m.hasType = true
+		if !p.consume(")") {
+			typ, err := p.name()
+			if err != nil {
+				return m, err
+			}
+			if !p.consume(")") {
+				return m, p.err("expected )")
+			}
+			m.typ = typ
+		}
+	}
+	if !p.eof() && p.query[p.pos] != '[' && isQueryNameStart(p.query[p.pos:]) {
+		name, err := p.name()
+		if err != nil {
+			return m, err
+		}
+		m.hasName = true
+		m.name = name
+	}
+	for p.peek("[") {
+		filter, err := p.filter()
+		if err != nil {
+			return m, err
+		}
+		m.filters = append(m.filters, filter)
+	}
+	if p.pos == m.position {
+		return m, p.err("expected a node name, type or filter")
+	}
+	return m, nil
+}
+
+func isQueryNameStart(s string) bool {
+	r, _ := utf8.DecodeRuneInString(s)
+	return r == '"' || isQueryNameRune(r)
+}
+
+func isQueryNameRune(r rune) bool {
+	return !unicode.IsSpace(r) && !strings.ContainsRune(`()[]{}<>=,"\/;|`, r)
+}
+
+// name reads a node name, type annotation or property key, either bare or
+// as a quoted string.
+func (p *queryParser) name() (string, error) {
+	start := p.pos
+	if p.peek(`"`) {
+		value, err := p.value()
+		if err != nil {
+			return "", err
+		}
+		return value.String, nil
+	}
+	for !p.eof() {
+		r, size := utf8.DecodeRuneInString(p.query[p.pos:])
+		if !isQueryNameRune(r) || strings.ContainsRune("!^$*", r) && strings.HasPrefix(p.query[p.pos+size:], "=") {
+			break
+		}
+		p.pos += size
+	}
+	if p.pos == start {
+		return "", p.err("expected a name")
+	}
+	return p.query[start:p.pos], nil
+}
+
+func (p *queryParser) filter() (queryFilter, error) {
+	var filter queryFilter
+	p.pos++
+	p.skipSpace()
+	if p.consume("]") {
+		return filter, nil
+	}
+
+	accessorPos := p.pos
+	acc, err := p.accessor()
+	if err != nil {
+		return filter, err
+	}
+	filter.accessor = acc
+	p.skipSpace()
+	if p.consume("]") {
+		return filter, nil
+	}
+
+	for _, op := range []string{"=", "!=", ">=", "<=", ">", "<", "^=", "$=", "*="} {
+		if p.peek(op) && (len(op) == 2 || !p.peek(op+"=")) {
+			filter.op = op
+			p.pos += len(op)
+			break
+		}
+	}
+	if len(filter.op) == 0 {
+		return filter, p.err("expected a comparison or ]")
+	}
+	if acc.kind == accessValues || acc.kind == accessProps {
+		return filter, p.errAt(accessorPos, acc.kind+"() can't be compared")
+	}
+
+	p.skipSpace()
+	if acc.kind == accessTag && p.consume("(") {
+		typ, err := p.name()
+		if err != nil {
+			return filter, err
+		}
+		if !p.consume(")") {
+			return filter, p.err("expected )")
+		}
+		filter.value = KDLValue{String: typ, Type: KDLStringType}
+	} else {
+		filter.value, err = p.value()
+		if err != nil {
+			return filter, err
+		}
+	}
+	p.skipSpace()
+	if !p.consume("]") {
+		return filter, p.err("expected ]")
+	}
+	return filter, nil
+}
+
+func (p *queryParser) accessor() (queryAccessor, error) {
+	for _, kind := range []string{accessName, accessTag, accessValues, accessProps} {
+		if p.consume(kind + "()") {
+			return queryAccessor{kind: kind}, nil
+		}
+	}
+
+	if p.consume(accessVal + "(") {
+		start := p.pos
+		for !p.eof() && p.query[p.pos] >= '0' && p.query[p.pos] <= '9' {
+			p.pos++
+		}
+		index := 0
+		if p.pos > start {
+			index, _ = strconv.Atoi(p.query[start:p.pos])
+		}
+		if !p.consume(")") {
+			return queryAccessor{}, p.err("expected an index or )")
+		}
+		return queryAccessor{kind: accessVal, index: index}, nil
+	}
+
+	if p.consume(accessProp + "(") {
+		key, err := p.name()
+		if err != nil {
+			return queryAccessor{}, err
+		}
+		if !p.consume(")") {
+			return queryAccessor{}, p.err("expected )")
+		}
+		return queryAccessor{kind: accessProp, key: key}, nil
+	}
+
+	key, err := p.name()
+	return queryAccessor{kind: accessProp, key: key}, err
+}
+
+// value reads a KDL value, with an optional type annotation, and parses it
+// as KDL 1.0 or, failing that, as KDL 2.0.
+func (p *queryParser) value() (KDLValue, error) {
+	start := p.pos
+	if p.consume("(") {
+		for !p.eof() && !p.peek(")") {
+			p.pos++
+		}
+		p.consume(")")
+	}
+
+	switch {
+	case p.peek(`"`):
+		p.pos++
+		for !p.eof() && !p.peek(`"`) {
+			if p.peek(`\`) {
+				p.pos++
+			}
+			p.pos++
+		}
+		p.consume(`"`)
+	case p.peek(`r"`) || p.peek("r#") || p.peek(`#"`) || p.peek("##"):
+		p.consume("r")
+		hashes := 0
+		for p.consume("#") {
+			hashes++
+		}
+		end := `"` + strings.Repeat("#", hashes)
+		if p.consume(`"`) {
+			for !p.eof() && !p.consume(end) {
+				p.pos++
+			}
+		}
+	default:
+		for !p.eof() {
+			r, size := utf8.DecodeRuneInString(p.query[p.pos:])
+			if unicode.IsSpace(r) || r == ']' {
+				break
+			}
+			p.pos += size
+		}
+	}
+
+	text := p.query[start:p.pos]
+	for _, version := range []KDLVersion{KDLVersion1, KDLVersion2} {
+		doc, err := ParseDocumentString("- "+text, WithVersion(version))
+		if err == nil && len(doc.nodes) == 1 && len(doc.nodes[0].args) == 1 &&
+			len(doc.nodes[0].props) == 0 && len(doc.nodes[0].children) == 0 {
+			return doc.nodes[0].args[0], nil
+		}
+	}
+	return KDLValue{}, p.errAt(start, "expected a value")
+}
@@ -0,0 +1,117 @@
+package kdlgo
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+const queryInput = `server "web" name="web" {
+    port 80
+    (tls)port 443
+    host "example.com"
+}
+server "db" name="db" replicas=3 {
+    port 5432
+    nested {
+        port 1
+    }
+}
+(legacy)server "old" name="old" replicas=10
+client name="web"
+`
+
+func TestQuery(t *testing.T) {
+	doc, err := ParseDocumentString(queryInput)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		query    string
+		expected []string
+	}{
+		{`top() > server[name="web"] port`, []string{"port 80", "(tls)port 443"}},
+		{`server port`, []string{"port 80", "(tls)port 443", "port 5432", "port 1"}},
+		{`server > port`, []string{"port 80", "(tls)port 443", "port 5432"}},
+		{`top() > port || top() port[val() < 10]`, []string{"port 1"}},
+		{`top()`, []string{`server "web"`, `server "db"`, `(legacy)server "old"`, `client`}},
+		{`[prop(replicas)]`, []string{`server "db"`, `(legacy)server "old"`}},
+		{`[replicas >= 3][replicas <= 5]`, []string{`server "db"`}},
+		{`port[val() > 100] || port[val() = 1]`, []string{"(tls)port 443", "port 5432", "port 1"}},
+		{`port + port`, []string{"(tls)port 443"}},
+		{`port ~ host`, []string{`host "example.com"`}},
+		{`server + server ~ client`, []string{`client`}},
+		{`()`, []string{"(tls)port 443", `(legacy)server "old"`}},
+		{`(tls)[]`, []string{"(tls)port 443"}},
+		{`[tag() = (legacy)]`, []string{`(legacy)server "old"`}},
+		{`[tag() != "legacy"]`, []string{"(tls)port 443"}},
+		{`[name() ^= "ho"]`, []string{`host "example.com"`}},
+		{`[val() $= ".com"] || [val() *= "l"]`, []string{`host "example.com"`, `(legacy)server "old"`}},
+		{`"client"[props()]`, []string{`client`}},
+		{`server[values()] > [val(1)]`, nil},
+		{`server[name!="web"][name!="old"]`, []string{`server "db"`}},
+		{`nested port`, []string{"port 1"}},
+		{`missing`, nil},
+	}
+	for _, test := range tests {
+		nodes, err := Query(doc, test.query)
+		if err != nil {
+			t.Errorf("%s: %s", test.query, err)
+			continue
+		}
+		var got []string
+		for _, node := range nodes {
+			got = append(got, queryNodeSummary(t, node))
+		}
+		if strings.Join(got, "; ") != strings.Join(test.expected, "; ") {
+			t.Errorf("%s: expected %q, got %q", test.query, test.expected, got)
+		}
+	}
+
+	q := MustCompileQuery("port")
+	if q.String() != "port" || len(q.Find(doc)) != 4 || len(q.Find(NewDocument())) != 0 {
+		t.Error("Expected a compiled query to be reusable")
+	}
+}
+
+func queryNodeSummary(t *testing.T, node *Node) string {
+	summary := NewNode(node.GetName())
+	summary.SetDeclaredType(node.GetDeclaredType())
+	if len(node.GetArgs()) > 0 {
+		summary.AddArg(node.GetArgs()[0])
+	}
+	s, err := summary.RecreateKDL()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func TestCompileQueryInvalid(t *testing.T) {
+	tests := map[string]int{
+		``:                 1,
+		`a >`:              4,
+		`a b(`:             4,
+		`a top()`:          3,
+		`[val(`:            6,
+		`[values() = 1]`:   2,
+		`[val() = ]`:       10,
+		`[val() ?= 1]`:     8,
+		`a ||`:             5,
+		`(a`:               3,
+		`[prop(a]`:         8,
+		`a[val() = "x" b]`: 15,
+	}
+	for query, column := range tests {
+		_, err := CompileQuery(query)
+		var parseErr *ParseError
+		if !errors.As(err, &parseErr) || !errors.Is(err, KDLInvalidQuery) {
+			t.Errorf("Expected %q to be invalid, got %v", query, err)
+			continue
+		}
+		if parseErr.Column != column {
+			t.Errorf("Expected the error for %q at column %d, got %d", query, column, parseErr.Column)
+		}
+	}
+}
@@ -3,33 +3,64 @@ package kdlgo
 import (
 	"bufio"
 	"bytes"
+	"unicode/utf8"
 )
 
 type kdlReader struct {
 	line    int
 	pos     int
+	offset  int
 	current rune
+	lineBuf []byte
 	reader  *bufio.Reader
+
+	options parseOptions
+	errs    ParseErrors
 }
 
+// maxSnippetPeek is how far ahead currentLine looks for the end of the line.
+const maxSnippetPeek = 256
+
 func newKDLReader(r *bufio.Reader) *kdlReader {
 	return &kdlReader{line: 1, pos: 0, reader: r}
 }
 
 func (kdlr *kdlReader) readRune() (rune, error) {
-	r, _, err := kdlr.reader.ReadRune()
-	if r == '\n' {
+	r, size, err := kdlr.reader.ReadRune()
+	if err != nil {
+		return r, err
+	}
+
+	if r == '\n' && kdlr.current == '\r' {
+		kdlr.pos = 0
+	} else if isNewline(r) {
 		kdlr.line++
 		kdlr.pos = 0
+		kdlr.lineBuf = kdlr.lineBuf[:0]
 	} else {
 		kdlr.pos++
+		kdlr.lineBuf = append(kdlr.lineBuf, string(r)...)
 	}
+	kdlr.offset += size
+	kdlr.current = r
 
-	if err == nil {
-		kdlr.current = r
+	return r, err
+}
+
+// currentLine returns the line that is currently being read, as far as it can
+// be seen without reading past the buffer.
+func (kdlr *kdlReader) currentLine() string {
+	rest, _ := kdlr.reader.Peek(maxSnippetPeek)
+	end := bytes.IndexAny(rest, "\r\n")
+	if end < 0 {
+		end = len(rest)
 	}
+	return string(kdlr.lineBuf) + string(rest[:end])
+}
 
-	return r, err
+// position returns the position of the next rune to be read.
+func (kdlr *kdlReader) position() Position {
+	return Position{Offset: kdlr.offset, Line: kdlr.line, Column: kdlr.pos + 1}
 }
 
 func (kdlr *kdlReader) lastRead() rune {
@@ -37,25 +68,38 @@ func (kdlr *kdlReader) lastRead() rune {
 }
 
 func (kdlr *kdlReader) discardLine() error {
-	_, err := kdlr.reader.ReadString('\n')
+	line, err := kdlr.reader.ReadString('\n')
+	kdlr.offset += len(line)
+	kdlr.lineBuf = append(kdlr.lineBuf, bytes.TrimRight([]byte(line), "\n")...)
 	if err != nil {
 		return err
 	}
 
 	err = kdlr.reader.UnreadByte()
+	if err == nil {
+		kdlr.offset--
+	}
 	return err
 }
 
 func (kdlr *kdlReader) discard(count int) {
 	s, _ := kdlr.peekX(count)
+	kdlr.offset += len(s)
 	for _, b := range s {
-		var nl byte = '\n'
-		if b == nl {
+		if b == '\n' && kdlr.current == '\r' {
+			kdlr.pos = 0
+		} else if b == '\n' || b == '\r' {
 			kdlr.line++
 			kdlr.pos = 0
+			kdlr.lineBuf = kdlr.lineBuf[:0]
+		} else if !utf8.RuneStart(b) {
+			kdlr.lineBuf = append(kdlr.lineBuf, b)
+			continue
 		} else {
 			kdlr.pos++
+			kdlr.lineBuf = append(kdlr.lineBuf, b)
 		}
+		kdlr.current = rune(b)
 	}
 	kdlr.reader.Discard(count)
 }
@@ -79,6 +123,7 @@ func (kdlr *kdlReader) unreadRune() error {
 	if err != nil {
 		return err
 	}
+	kdlr.offset -= utf8.RuneLen(kdlr.current)
 
 	peek, _ := kdlr.reader.Peek(1)
 	var b byte = '\n'
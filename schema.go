@@ -0,0 +1,587 @@
+package kdlgo
+
+import (
+	"errors"
+	"regexp"
+	"strconv"
+	"unicode/utf8"
+)
+
+// Schema is a compiled KDL Schema Language document that other documents can
+// be validated against. A schema looks like:
+//
+//	document {
+//	    info { title "Server config"; }
+//	    node "server" {
+//	        min 1
+//	        value { min 1; max 1; type "string"; }
+//	        prop "port" { required true; type "number"; ">" 0; "<" 65536; }
+//	        children {
+//	            node "mode" { value { enum "dev" "prod"; }; }
+//	            node ref=r#"[id="tls"]"#
+//	        }
+//	    }
+//	    definitions {
+//	        node "tls" id="tls" { prop "cert" { pattern r"\.pem$"; }; }
+//	    }
+//	}
+//
+// Rules for nodes are:
+//
+//	min, max               how many sibling nodes with the name there can be
+//	value                  the validations of the arguments, where min and
+//	                       max are how many there can be
+//	prop "key"             the validations of a property, and whether it is
+//	                       required
+//	other-props-allowed    whether properties without a prop rule are allowed,
+//	                       which they aren't by default
+//	children               the rules of the children, which are unrestricted
+//	                       without it
+//
+// A node rule without a name applies to any node without a rule of its own.
+// The document and children blocks take node rules, node-names with the
+// validations of the names of the nodes, and other-nodes-allowed, which is
+// false by default. The validations of values are:
+//
+//	type                   "string", "number", "boolean", "null" or a type
+//	                       annotation registered with DefaultAnnotations
+//	enum                   the values allowed
+//	pattern                a regular expression strings have to match
+//	min-length, max-length the length of strings, in characters
+//	format                 annotations registered with DefaultAnnotations the
+//	                       value has to satisfy, e.g. "date-time" or "email"
+//	">", ">=", "<", "<="   the range of numbers
+//
+// Any rule can instead be a ref property with a KQL query for the rule in the
+// definitions block.
+type Schema struct {
+	rules *schemaNodes
+}
+
+// schemaNodes are the rules for the nodes of a document or children block.
+type schemaNodes struct {
+	nodes             []*schemaNode
+	nodeNames         *schemaValue
+	otherNodesAllowed bool
+}
+
+type schemaNode struct {
+	name              string
+	hasName           bool
+	min               int
+	max               int
+	values            *schemaValue
+	props             []*schemaProp
+	otherPropsAllowed bool
+	children          *schemaNodes
+}
+
+type schemaProp struct {
+	key      string
+	required bool
+	value    *schemaValue
+}
+
+// schemaValue are the validations of a value, or of the arguments of a node
+// in which case min and max are how many there can be.
+type schemaValue struct {
+	min       int
+	max       int
+	types     []string
+	enum      []KDLValue
+	patterns  []*regexp.Regexp
+	minLength int
+	maxLength int
+	formats   []string
+	ranges    []schemaRange
+}
+
+type schemaRange struct {
+	op    string
+	bound KDLValue
+}
+
+// NewSchema compiles a schema document. The error is an *UnmarshalError
+// naming the rule if the document isn't a valid schema.
+func NewSchema(doc *Document) (*Schema, error) {
+	root := doc.First("document")
+	if root == nil || len(doc.nodes) != 1 {
+		return nil, &UnmarshalError{
+			Kind: KDLInvalidSchema,
+			Err:  errors.New("a schema should have a single document node"),
+		}
+	}
+
+	c := &schemaCompiler{nodes: map[*Node]*schemaNode{}}
+	if defs := root.First("definitions"); defs != nil {
+		c.definitions = NewDocument(defs.children...)
+	}
+	rules, err := c.compileNodes(root, root.name)
+	if err != nil {
+		return nil, err
+	}
+	return &Schema{rules: rules}, nil
+}
+
+type schemaCompiler struct {
+	definitions *Document
+	// nodes are the node rules already compiled, so that definitions can
+	// refer to themselves.
+	nodes map[*Node]*schemaNode
+}
+
+// resolve returns the definition a rule refers to with its ref property, or
+// the rule itself if it has none.
+func (c *schemaCompiler) resolve(rule *Node, path string) (*Node, error) {
+	ref, ok := rule.GetProp("ref")
+	if !ok {
+		return rule, nil
+	}
+	query, isString := queryString(ref)
+	if !isString || c.definitions == nil {
+		return nil, nodeErr(KDLInvalidSchema, rule, path, "ref should be a query for a rule in definitions")
+	}
+	q, err := CompileQuery(query)
+	if err != nil {
+		return nil, nodeErr(KDLInvalidSchema, rule, path, err.Error())
+	}
+	matches := q.Find(c.definitions)
+	if len(matches) == 0 {
+		return nil, nodeErr(KDLInvalidSchema, rule, path, "no definition matches "+query)
+	}
+	return matches[0], nil
+}
+
+func (c *schemaCompiler) compileNodes(block *Node, path string) (*schemaNodes, error) {
+	block, err := c.resolve(block, path)
+	if err != nil {
+		return nil, err
+	}
+
+	rules := &schemaNodes{}
+	for _, child := range block.children {
+		rulePath := childPath(path, child.name)
+		switch child.name {
+		case "node":
+			node, err := c.compileNode(child, rulePath)
+			if err != nil {
+				return nil, err
+			}
+			rules.nodes = append(rules.nodes, node)
+		case "node-names":
+			rules.nodeNames, err = c.compileValue(child, rulePath)
+		case "other-nodes-allowed":
+			rules.otherNodesAllowed, err = schemaBool(child, rulePath)
+		case "info", "definitions":
+		default:
+			err = nodeErr(KDLInvalidSchema, child, rulePath, "unknown rule "+child.name)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return rules, nil
+}
+
+func (c *schemaCompiler) compileNode(rule *Node, path string) (*schemaNode, error) {
+	rule, err := c.resolve(rule, path)
+	if err != nil {
+		return nil, err
+	}
+	if node, ok := c.nodes[rule]; ok {
+		return node, nil
+	}
+
+	node := &schemaNode{max: -1}
+	c.nodes[rule] = node
+	if len(rule.args) > 0 {
+		node.name, node.hasName = queryString(rule.args[0])
+		if !node.hasName {
+			return nil, nodeErr(KDLInvalidSchema, rule, path, "the name of a node should be a string")
+		}
+	}
+
+	for _, child := range rule.children {
+		rulePath := childPath(path, child.name)
+		switch child.name {
+		case "min":
+			node.min, err = schemaInt(child, rulePath)
+		case "max":
+			node.max, err = schemaInt(child, rulePath)
+		case "value":
+			node.values, err = c.compileValue(child, rulePath)
+		case "prop":
+			var prop *schemaProp
+			prop, err = c.compileProp(child, rulePath)
+			node.props = append(node.props, prop)
+		case "other-props-allowed":
+			node.otherPropsAllowed, err = schemaBool(child, rulePath)
+		case "children":
+			node.children, err = c.compileNodes(child, rulePath)
+		default:
+			err = nodeErr(KDLInvalidSchema, child, rulePath, "unknown rule "+child.name)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return node, nil
+}
+
+func (c *schemaCompiler) compileProp(rule *Node, path string) (*schemaProp, error) {
+	rule, err := c.resolve(rule, path)
+	if err != nil {
+		return nil, err
+	}
+	prop := &schemaProp{}
+	isString := false
+	if len(rule.args) == 1 {
+		prop.key, isString = queryString(rule.args[0])
+	}
+	if !isString {
+		return nil, nodeErr(KDLInvalidSchema, rule, path, "prop should have the key of the property")
+	}
+
+	// The validations of the value are in the prop rule itself, next to
+	// required.
+	validations := NewNode(rule.name)
+	for _, child := range rule.children {
+		if child.name != "required" {
+			validations.AddChild(child)
+			continue
+		}
+		prop.required, err = schemaBool(child, childPath(path, child.name))
+		if err != nil {
+			return nil, err
+		}
+	}
+	prop.value, err = c.compileValue(validations, path)
+	return prop, err
+}
+
+func (c *schemaCompiler) compileValue(rule *Node, path string) (*schemaValue, error) {
+	rule, err := c.resolve(rule, path)
+	if err != nil {
+		return nil, err
+	}
+
+	value := &schemaValue{max: -1, maxLength: -1}
+	for _, child := range rule.children {
+		rulePath := childPath(path, child.name)
+		switch child.name {
+		case "min":
+			value.min, err = schemaInt(child, rulePath)
+		case "max":
+			value.max, err = schemaInt(child, rulePath)
+		case "min-length":
+			value.minLength, err = schemaInt(child, rulePath)
+		case "max-length":
+			value.maxLength, err = schemaInt(child, rulePath)
+		case "type":
+			value.types, err = schemaStrings(child, rulePath)
+			for _, t := range value.types {
+				if !isSchemaType(t) && err == nil {
+					err = nodeErr(KDLInvalidSchema, child, rulePath, "unknown type "+t)
+				}
+			}
+		case "format":
+			value.formats, err = schemaStrings(child, rulePath)
+			for _, format := range value.formats {
+				if _, ok := DefaultAnnotations.lookup(format); !ok && err == nil {
+					err = nodeErr(KDLInvalidSchema, child, rulePath, "unknown format "+format)
+				}
+			}
+		case "enum":
+			value.enum = child.args
+		case "pattern":
+			var patterns []string
+			patterns, err = schemaStrings(child, rulePath)
+			for _, pattern := range patterns {
+				re, compileErr := regexp.Compile(pattern)
+				if compileErr != nil {
+					return nil, nodeErr(KDLInvalidSchema, child, rulePath, compileErr.Error())
+				}
+				value.patterns = append(value.patterns, re)
+			}
+		case ">", ">=", "<", "<=":
+			if len(child.args) != 1 || child.args[0].Type != KDLNumberType {
+				return nil, nodeErr(KDLInvalidSchema, child, rulePath, child.name+" should have a number")
+			}
+			value.ranges = append(value.ranges, schemaRange{op: child.name, bound: child.args[0]})
+		default:
+			err = nodeErr(KDLInvalidSchema, child, rulePath, "unknown validation "+child.name)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return value, nil
+}
+
+// isSchemaType reports whether t is one of the types a value can be validated
+// against, see Schema.
+func isSchemaType(t string) bool {
+	switch t {
+	case "string", "number", "boolean", "null":
+		return true
+	}
+	_, ok := DefaultAnnotations.lookup(t)
+	return ok
+}
+
+func schemaInt(rule *Node, path string) (int, error) {
+	if len(rule.args) == 1 {
+		if i, ok := rule.args[0].Int64(); ok && i >= 0 {
+			return int(i), nil
+		}
+	}
+	return 0, nodeErr(KDLInvalidSchema, rule, path, rule.name+" should have a non-negative integer")
+}
+
+func schemaBool(rule *Node, path string) (bool, error) {
+	if len(rule.args) != 1 || rule.args[0].Type != KDLBoolType {
+		return false, nodeErr(KDLInvalidSchema, rule, path, rule.name+" should have a boolean")
+	}
+	return rule.args[0].Bool, nil
+}
+
+func schemaStrings(rule *Node, path string) ([]string, error) {
+	var strs []string
+	for _, arg := range rule.args {
+		s, ok := queryString(arg)
+		if !ok {
+			return nil, nodeErr(KDLInvalidSchema, rule, path, rule.name+" should only have strings")
+		}
+		strs = append(strs, s)
+	}
+	if len(strs) == 0 {
+		return nil, nodeErr(KDLInvalidSchema, rule, path, rule.name+" should have a string")
+	}
+	return strs, nil
+}
+
+// Validate checks the document against the schema, returning SchemaErrors
+// with every violation in the order they were found, or nil if there are
+// none.
+func (schema *Schema) Validate(doc *Document) error {
+	v := &schemaValidator{}
+	v.nodes(schema.rules, doc.nodes, nil, "")
+	if len(v.errs) > 0 {
+		return v.errs
+	}
+	return nil
+}
+
+type schemaValidator struct {
+	errs SchemaErrors
+}
+
+func (v *schemaValidator) violation(span Span, path string, reason string) {
+	v.errs = append(v.errs, &SchemaError{
+		Path:   path,
+		Line:   span.Start.Line,
+		Column: span.Start.Column,
+		Err:    errors.New(reason),
+	})
+}
+
+// nodes validates the nodes of a document, or the children of parent.
+func (v *schemaValidator) nodes(rules *schemaNodes, nodes []*Node, parent *Node, path string) {
+	matches := map[*schemaNode][]*Node{}
+	for _, node := range nodes {
+		nodePath := childPath(path, node.name)
+		if rules.nodeNames != nil {
+			name := KDLValue{String: node.name, Type: KDLStringType, span: node.nameSpan}
+			v.value(rules.nodeNames, name, nodePath, "node name")
+		}
+
+		rule := rules.match(node)
+		if rule == nil {
+			if !rules.otherNodesAllowed {
+				v.violation(node.span, nodePath, "node "+node.name+" isn't allowed here")
+			}
+			continue
+		}
+		matches[rule] = append(matches[rule], node)
+		v.node(rule, node, nodePath)
+	}
+
+	// Missing nodes are reported at their parent, or at the first node of the
+	// document if they are missing from the top level.
+	var span Span
+	if parent != nil {
+		span = parent.span
+	} else if len(nodes) > 0 {
+		span = nodes[0].span
+	}
+	for _, rule := range rules.nodes {
+		name := "any name"
+		if rule.hasName {
+			name = rule.name
+		}
+		count := len(matches[rule])
+		if count < rule.min {
+			v.violation(span, path, "expected at least "+strconv.Itoa(rule.min)+" nodes named "+name+
+				" but got "+strconv.Itoa(count))
+		}
+		if rule.max >= 0 && count > rule.max {
+			extra := matches[rule][rule.max]
+			v.violation(extra.span, childPath(path, extra.name), "expected at most "+strconv.Itoa(rule.max)+
+				" nodes named "+name+" but got "+strconv.Itoa(count))
+		}
+	}
+}
+
+// match returns the rule for the node, preferring one for its name.
+func (rules *schemaNodes) match(node *Node) *schemaNode {
+	var anyName *schemaNode
+	for _, rule := range rules.nodes {
+		if rule.hasName && rule.name == node.name {
+			return rule
+		}
+		if !rule.hasName && anyName == nil {
+			anyName = rule
+		}
+	}
+	return anyName
+}
+
+func (v *schemaValidator) node(rule *schemaNode, node *Node, path string) {
+	if rule.values != nil {
+		count := len(node.args)
+		if count < rule.values.min {
+			v.violation(node.span, path, "expected at least "+strconv.Itoa(rule.values.min)+
+				" arguments but got "+strconv.Itoa(count))
+		}
+		if rule.values.max >= 0 && count > rule.values.max {
+			v.violation(node.args[rule.values.max].span, path, "expected at most "+
+				strconv.Itoa(rule.values.max)+" arguments but got "+strconv.Itoa(count))
+		}
+		for _, arg := range node.args {
+			v.value(rule.values, arg, path, "argument")
+		}
+	}
+
+	for _, prop := range dedupeProps(node.props) {
+		propRule := rule.prop(prop.key)
+		switch {
+		case propRule != nil:
+			v.value(propRule.value, prop.value, path, "property "+prop.key)
+		case !rule.otherPropsAllowed:
+			v.violation(prop.span, path, "property "+prop.key+" isn't allowed")
+		}
+	}
+	for _, propRule := range rule.props {
+		if _, ok := node.GetProp(propRule.key); propRule.required && !ok {
+			v.violation(node.span, path, "missing required property "+propRule.key)
+		}
+	}
+
+	if rule.children != nil {
+		v.nodes(rule.children, node.children, node, path)
+	}
+}
+
+func (rule *schemaNode) prop(key string) *schemaProp {
+	for _, prop := range rule.props {
+		if prop.key == key {
+			return prop
+		}
+	}
+	return nil
+}
+
+// value validates an argument, property or node name, described by what.
+func (v *schemaValidator) value(rule *schemaValue, value KDLValue, path string, what string) {
+	if len(rule.types) > 0 && !schemaHasType(rule.types, value) {
+		v.violation(value.span, path, what+" should be of type "+schemaList(rule.types))
+		return
+	}
+
+	if len(rule.enum) > 0 {
+		isAllowed := false
+		for _, allowed := range rule.enum {
+			isAllowed = isAllowed || queryValuesEqual(value, allowed)
+		}
+		if !isAllowed {
+			s, _ := value.RecreateKDL()
+			v.violation(value.span, path, what+" can't be "+s)
+		}
+	}
+
+	if s, ok := queryString(value); ok {
+		for _, pattern := range rule.patterns {
+			if !pattern.MatchString(s) {
+				v.violation(value.span, path, what+" should match "+pattern.String())
+			}
+		}
+		length := utf8.RuneCountInString(s)
+		if length < rule.minLength {
+			v.violation(value.span, path, what+" should be at least "+strconv.Itoa(rule.minLength)+
+				" characters long")
+		}
+		if rule.maxLength >= 0 && length > rule.maxLength {
+			v.violation(value.span, path, what+" should be at most "+strconv.Itoa(rule.maxLength)+
+				" characters long")
+		}
+	}
+
+	for _, format := range rule.formats {
+		converter, _ := DefaultAnnotations.lookup(format)
+		if _, err := converter(value); err != nil {
+			v.violation(value.span, path, what+" doesn't have the "+format+" format: "+err.Error())
+		}
+	}
+
+	for _, r := range rule.ranges {
+		if !compareQueryValues(value, r.op, r.bound) {
+			v.violation(value.span, path, what+" should be "+r.op+" "+r.bound.NumberString())
+		}
+	}
+}
+
+func schemaHasType(types []string, value KDLValue) bool {
+	for _, t := range types {
+		switch t {
+		case "string":
+			if _, ok := queryString(value); ok {
+				return true
+			}
+		case "number":
+			if value.Type == KDLNumberType {
+				return true
+			}
+		case "boolean":
+			if value.Type == KDLBoolType {
+				return true
+			}
+		case "null":
+			if value.Type == KDLNullType {
+				return true
+			}
+		default:
+			converter, ok := DefaultAnnotations.lookup(t)
+			if ok && value.Type != KDLNullType {
+				if _, err := converter(value); err == nil {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+func schemaList(strs []string) string {
+	s := ""
+	for i, str := range strs {
+		switch {
+		case i == 0:
+		case i == len(strs)-1:
+			s += " or "
+		default:
+			s += ", "
+		}
+		s += str
+	}
+	return s
+}
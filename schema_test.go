@@ -0,0 +1,165 @@
+package kdlgo
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+const schemaInput = `document {
+    info {
+        title "Server config"
+    }
+    node "server" {
+        min 1
+        max 2
+        value {
+            min 1
+            max 1
+            type "string"
+            pattern "^[a-z]+$"
+            max-length 8
+        }
+        prop "port" {
+            required true
+            type "u16"
+            ">" 1000
+        }
+        prop "address" {
+            format "ipv4"
+        }
+        children {
+            node "mode" {
+                max 1
+                value {
+                    enum "dev" "prod"
+                }
+            }
+            node ref="[id=\"tls\"]"
+        }
+    }
+    node {
+        other-props-allowed true
+    }
+    node-names {
+        min-length 3
+    }
+    definitions {
+        node "tls" id="tls" {
+            prop "cert" {
+                type "string"
+                pattern "\\.pem$"
+            }
+            children ref="[id=\"tls-children\"]"
+        }
+        children id="tls-children" {
+            node "tls" {
+                max 0
+            }
+        }
+    }
+}
+`
+
+func TestSchema(t *testing.T) {
+	schema, err := ParseSchemaString(schemaInput)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	valid, err := ParseDocumentString(`server "web" port=8080 address="10.0.0.1" {
+    mode "prod"
+    tls cert="web.pem"
+}
+extra anything=true
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := schema.Validate(valid); err != nil {
+		t.Errorf("Expected the document to be valid, got:\n%s", err)
+	}
+
+	invalid, err := ParseDocumentString(`server "Web" "db" port=80 {
+    mode "test"
+    mode "dev"
+    tls cert="web.crt" key="web.key" {
+        tls
+    }
+}
+server 1 address="::1"
+server "toolongname" port=2000
+ab
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = schema.Validate(invalid)
+	var errs SchemaErrors
+	if !errors.As(err, &errs) || !errors.Is(err, KDLSchemaViolation) {
+		t.Fatalf("Expected SchemaErrors, got %v", err)
+	}
+
+	expected := []string{
+		`1:14 server: expected at most 1 arguments but got 2`,
+		`1:8 server: argument should match ^[a-z]+$`,
+		`1:24 server: property port should be > 1000`,
+		`2:10 server > mode: argument can't be "test"`,
+		`4:14 server > tls: property cert should match \.pem$`,
+		`4:24 server > tls: property key isn't allowed`,
+		`5:9 server > tls > tls: expected at most 0 nodes named tls but got 1`,
+		`3:5 server > mode: expected at most 1 nodes named mode but got 2`,
+		`8:8 server: argument should be of type string`,
+		`8:18 server: property address doesn't have the ipv4 format: not an IP address of the right version`,
+		`8:1 server: missing required property port`,
+		`9:8 server: argument should be at most 8 characters long`,
+		`10:1 ab: node name should be at least 3 characters long`,
+		`9:1 server: expected at most 2 nodes named server but got 3`,
+	}
+	var got []string
+	for _, err := range errs {
+		got = append(got, strconv.Itoa(err.Line)+":"+strconv.Itoa(err.Column)+" "+err.Path+": "+err.Err.Error())
+	}
+	if strings.Join(got, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("Expected:\n%s\nGot:\n%s", strings.Join(expected, "\n"), strings.Join(got, "\n"))
+	}
+
+	var first *SchemaError
+	if !errors.As(err, &first) || first != errs[0] {
+		t.Errorf("Expected errors.As to find the first violation, got %v", first)
+	}
+
+	missing, err := ParseDocumentString("// No servers\nextra 1\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = schema.Validate(missing)
+	if !errors.As(err, &first) || first.Line != 2 || first.Column != 1 ||
+		first.Err.Error() != "expected at least 1 nodes named server but got 0" {
+		t.Errorf("Expected the missing server to be reported at the first node, got %v", err)
+	}
+}
+
+func TestSchemaInvalid(t *testing.T) {
+	invalid := []string{
+		`node "a"`,
+		`document; document`,
+		`document { node 1; }`,
+		`document { node "a" { min "1"; }; }`,
+		`document { node "a" { unknown 1; }; }`,
+		`document { node "a" { value { pattern "("; }; }; }`,
+		`document { node "a" { value { format "nope"; }; }; }`,
+		`document { node "a" { value { type "strnig"; }; }; }`,
+		`document { node "a" { prop { required true; }; }; }`,
+		`document { node ref="[id=\"missing\"]"; definitions; }`,
+		`document { node ref="[[["; definitions; }`,
+		`document { other-nodes-allowed "yes"; }`,
+	}
+	for _, s := range invalid {
+		_, err := ParseSchemaString(s)
+		if !errors.Is(err, KDLInvalidSchema) {
+			t.Errorf("Expected %q to be an invalid schema, got %v", s, err)
+		}
+	}
+}
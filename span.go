@@ -0,0 +1,22 @@
+package kdlgo
+
+// Position is a location in the parsed source. Offset is in bytes and starts
+// at 0 while Line and Column are counted in runes and start at 1.
+type Position struct {
+	Offset int
+	Line   int
+	Column int
+}
+
+// Span is the range of the source from Start (inclusive) to End (exclusive)
+// that a parsed element was read from.
+type Span struct {
+	Start Position
+	End   Position
+}
+
+// IsZero reports whether the span is unset, which is the case for anything
+// that wasn't created by the parser.
+func (span Span) IsZero() bool {
+	return span == Span{}
+}
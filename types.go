@@ -1,6 +1,7 @@
 package kdlgo
 
 import (
+	"math"
 	"math/big"
 	"strconv"
 	"strings"
@@ -34,20 +35,56 @@ type KDLValue struct {
 
 	Type         KDLType
 	declaredType string
+	span         Span
+	typeSpan     Span
+	numberKind   KDLNumberKind
+	integer      *big.Int
+	decimal      *kdlDecimal
+	nan          bool
+}
+
+// GetDeclaredType returns the type annotation of the value, e.g. "u8" for
+// `(u8)123`, or an empty string if it has none.
+func (kdlValue KDLValue) GetDeclaredType() string {
+	return kdlValue.declaredType
+}
+
+func (kdlValue *KDLValue) SetDeclaredType(declaredType string) {
+	kdlValue.declaredType = declaredType
+}
+
+// GetSpan returns where the value, including its type annotation, was read
+// from. It is only set for values returned by the ParseDocument functions.
+func (kdlValue KDLValue) GetSpan() Span {
+	return kdlValue.span
+}
+
+// GetTypeSpan returns where the type annotation of the value was read from.
+func (kdlValue KDLValue) GetTypeSpan() Span {
+	return kdlValue.typeSpan
 }
 
 func (kdlValue KDLValue) RecreateKDL() (string, error) {
+	s, err := kdlValue.recreateKDLValue()
+	if err != nil || len(kdlValue.declaredType) < 1 {
+		return s, err
+	}
+	return "(" + recreateKey(kdlValue.declaredType) + ")" + s, nil
+}
+
+func (kdlValue KDLValue) recreateKDLValue() (string, error) {
 	switch kdlValue.Type {
 	case KDLBoolType:
 		return strconv.FormatBool(kdlValue.Bool), nil
 	case KDLNumberType:
-		num := kdlValue.Number
-		f64, _ := num.Float64()
-		return strconv.FormatFloat(f64, 'f', -1, 64), nil
+		if kdlValue.nan || kdlValue.Number.IsInf() {
+			return "", nonFiniteNumberErr()
+		}
+		return kdlValue.NumberString(), nil
 	case KDLStringType:
 		return RecreateString(kdlValue.String), nil
 	case KDLRawStringType:
-		return RecreateString(kdlValue.RawString), nil
+		return RecreateRawString(kdlValue.RawString), nil
 	case KDLDocumentType:
 		var s strings.Builder
 		for i, v := range kdlValue.Document {
@@ -84,7 +121,17 @@ func (kdlValue KDLValue) RecreateKDL() (string, error) {
 }
 
 func RecreateString(s string) string {
-	return strings.ReplaceAll(strconv.Quote(s), "/", "\\/")
+	return "\"" + escapeString(s) + "\""
+}
+
+// RecreateRawString writes s as a raw string with as few '#' as it needs to
+// end at the right quote.
+func RecreateRawString(s string) string {
+	hashes := ""
+	for strings.Contains(s, "\""+hashes) {
+		hashes += "#"
+	}
+	return "r" + hashes + "\"" + s + "\"" + hashes
 }
 
 func (kdlValue KDLValue) ToString() (string, error) {
@@ -108,16 +155,39 @@ type KDLObject interface {
 func RecreateKDLObj(kdlObj KDLObject) (string, error) {
 	s, err := kdlObj.GetValue().RecreateKDL()
 	if err != nil {
-		return "", nil
+		return "", err
 	}
 	if len(s) > 0 {
 		s = " " + s
 	}
-	key := kdlObj.GetKey()
-	if strings.Contains(key, " ") || strconv.Quote(key) != "\""+key+"\"" {
-		key = strconv.Quote(key)
+	return recreateKey(kdlObj.GetKey()) + s, nil
+}
+
+func recreateKey(key string) string {
+	if !isBareIdentifier(key) {
+		return RecreateString(key)
 	}
-	return key + s, nil
+	return key
+}
+
+// isBareIdentifier reports whether s can be written as an identifier without
+// quotes, i.e. it isn't a keyword, doesn't look like a number or raw string and
+// only has characters that are allowed in bare identifiers.
+func isBareIdentifier(s string) bool {
+	switch s {
+	case "", "true", "false", "null":
+		return false
+	}
+	if isNumberToken(s) || strings.HasPrefix(s, "r#") {
+		return false
+	}
+	for _, r := range s {
+		if isWhitespace(r) || isNewline(r) || needsUnicodeEscape(r) ||
+			strings.ContainsRune(nonIdentifierChars, r) {
+			return false
+		}
+	}
+	return true
 }
 
 type KDLBool struct {
@@ -143,7 +213,36 @@ type KDLNumber struct {
 }
 
 func NewKDLNumber(key string, value float64) KDLNumber {
-	return KDLNumber{key: key, value: KDLValue{Number: *big.NewFloat(value), Type: KDLNumberType}}
+	if math.IsNaN(value) {
+		return KDLNumber{key: key, value: newNaNValue()}
+	}
+	dec, err := parseDecimal(strconv.FormatFloat(value, 'g', -1, 64))
+	if err != nil {
+		return KDLNumber{key: key, value: KDLValue{Number: *big.NewFloat(value), Type: KDLNumberType, numberKind: KDLFloatKind}}
+	}
+	return KDLNumber{key: key, value: newDecimalValue(dec)}
+}
+
+func NewKDLInt(key string, value int64) KDLNumber {
+	return NewKDLBigInt(key, big.NewInt(value))
+}
+
+func NewKDLUint(key string, value uint64) KDLNumber {
+	return NewKDLBigInt(key, new(big.Int).SetUint64(value))
+}
+
+func NewKDLBigInt(key string, value *big.Int) KDLNumber {
+	return KDLNumber{key: key, value: newIntegerValue(new(big.Int).Set(value))}
+}
+
+// NewKDLDecimal creates a float number from its decimal representation, e.g.
+// "1.23E+1000", without losing any precision.
+func NewKDLDecimal(key string, value string) (KDLNumber, error) {
+	dec, err := parseDecimal(strings.ReplaceAll(value, "_", ""))
+	if err != nil {
+		return KDLNumber{}, err
+	}
+	return KDLNumber{key: key, value: newDecimalValue(dec)}, nil
 }
 
 func (kdlNode KDLNumber) GetKey() string {
@@ -159,9 +258,13 @@ type KDLString struct {
 	value KDLValue
 }
 
+// NewKDLString creates a string from the body of a KDL 1.0 quoted string, so
+// any escapes in value are resolved. A value with invalid escapes is kept as is.
 func NewKDLString(key string, value string) KDLString {
-	value = strings.ReplaceAll(value, "\n", "\\n")
-	s, _ := strconv.Unquote(`"` + value + `"`)
+	s, err := unescapeString(value)
+	if err != nil {
+		s = value
+	}
 	return KDLString{key: key, value: KDLValue{String: s, Type: KDLStringType}}
 }
 
@@ -258,6 +361,34 @@ func (kdlNode KDLObjects) GetValue() KDLValue {
 	return kdlNode.value
 }
 
+// First returns the first object with the key, or nil if there is none.
+func (kdlObjs KDLObjects) First(key string) KDLObject {
+	for _, obj := range kdlObjs.GetValue().Objects {
+		if obj.GetKey() == key {
+			return obj
+		}
+	}
+	return nil
+}
+
+// All returns every object with the key in the order they appear in.
+func (kdlObjs KDLObjects) All(key string) []KDLObject {
+	var matches []KDLObject
+	for _, obj := range kdlObjs.GetValue().Objects {
+		if obj.GetKey() == key {
+			matches = append(matches, obj)
+		}
+	}
+	return matches
+}
+
+// Has reports whether there is an object with the key.
+func (kdlObjs KDLObjects) Has(key string) bool {
+	return kdlObjs.First(key) != nil
+}
+
+// ToObjMap returns the objects by their key. Only the last of any objects
+// sharing a key is kept, so use All to get every one of them.
 func (kdlObjs KDLObjects) ToObjMap() KDLObjectsMap {
 	ret := make(KDLObjectsMap)
 	for _, obj := range kdlObjs.GetValue().Objects {
@@ -266,6 +397,8 @@ func (kdlObjs KDLObjects) ToObjMap() KDLObjectsMap {
 	return ret
 }
 
+// ToValueMap returns the values of the objects by their key. Only the last of
+// any objects sharing a key is kept, so use All to get every one of them.
 func (kdlObjs KDLObjects) ToValueMap() KDLValuesMap {
 	ret := make(KDLValuesMap)
 	for _, obj := range kdlObjs.GetValue().Objects {
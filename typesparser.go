@@ -1,14 +1,20 @@
 package kdlgo
 
 import (
-	"strconv"
+	"errors"
+	"io"
+	"math/big"
 	"strings"
 	"unicode"
 )
 
 func checkQuotedString(s strings.Builder) string {
 	ss := s.String()
-	unquoted, err := strconv.Unquote(ss)
+	if len(ss) < 2 || ss[0] != dquote || ss[len(ss)-1] != dquote {
+		return ss
+	}
+
+	unquoted, err := unescapeString(ss[1 : len(ss)-1])
 	if err != nil {
 		return ss
 	} else {
@@ -22,7 +28,11 @@ func parseString(kdlr *kdlReader, key string) (KDLString, error) {
 	if err != nil {
 		return kdls, err
 	}
-	return NewKDLString(key, s), nil
+	s, err = unescapeString(s)
+	if err != nil {
+		return kdls, err
+	}
+	return KDLString{key: key, value: KDLValue{String: s, Type: KDLStringType}}, nil
 }
 
 func parseQuotedString(kdlr *kdlReader) (string, error) {
@@ -32,7 +42,7 @@ func parseQuotedString(kdlr *kdlReader) (string, error) {
 		bytes, err := kdlr.peekX(count)
 		if err != nil {
 			kdlr.discard(count)
-			return stringEscape(string(bytes[1:])), err
+			return string(bytes[1:]), err
 		}
 		r := rune(bytes[len(bytes)-1])
 
@@ -40,7 +50,7 @@ func parseQuotedString(kdlr *kdlReader) (string, error) {
 			bs, err := kdlr.peekX(count + 1)
 			if err != nil {
 				kdlr.discard(count)
-				return stringEscape(string(bytes[1:])), err
+				return string(bytes[1:]), err
 			}
 			next := bs[len(bs)-1] == byte(dquote)
 
@@ -53,9 +63,8 @@ func parseQuotedString(kdlr *kdlReader) (string, error) {
 		if r == dquote {
 			toRet := string(bytes[1 : len(bytes)-1])
 			temp, err := kdlr.peekX(count + 1)
-			toRet = stringEscape(toRet)
 			if err != nil {
-				if err.Error() != eof {
+				if !errors.Is(err, io.EOF) {
 
 					return toRet, err
 				}
@@ -75,10 +84,6 @@ func parseQuotedString(kdlr *kdlReader) (string, error) {
 	}
 }
 
-func stringEscape(s string) string {
-	return strings.ReplaceAll(s, "\\/", "/")
-}
-
 func parseRawString(kdlr *kdlReader, key string) (KDLRawString, error) {
 	var kdlrs KDLRawString
 	count := 0
@@ -146,7 +151,7 @@ func parseNumber(kdlr *kdlReader, key string) (KDLNumber, error) {
 	for {
 		length++
 		bytes, err := kdlr.peekX(length)
-		if err != nil && err.Error() != eof {
+		if err != nil && !errors.Is(err, io.EOF) {
 			return kdlnum, err
 		}
 		r := rune(bytes[len(bytes)-1])
@@ -158,24 +163,148 @@ func parseNumber(kdlr *kdlReader, key string) (KDLNumber, error) {
 		}
 
 		if r == semicolon || unicode.IsSpace(r) ||
-			r == slash || (err != nil && err.Error() == eof) {
+			r == slash || (err != nil && errors.Is(err, io.EOF)) {
 			rawStr := string(bytes[0 : len(bytes)-1])
-			if err != nil && err.Error() == eof {
+			if err != nil && errors.Is(err, io.EOF) {
 				rawStr = string(bytes)
 			}
-			str := strings.ReplaceAll(rawStr, "_", "")
-			value, err := strconv.ParseFloat(str, 64)
+			value, err := parseNumberString(rawStr)
 			if err != nil {
-				val, err := strconv.ParseInt(str, 0, 10)
-				if err != nil {
-					return kdlnum, err
-				}
-				value = float64(val)
+				return kdlnum, err
 			}
 			kdlr.discard(length - 1)
-			return NewKDLNumber(key, value), nil
+			return KDLNumber{key: key, value: value}, nil
+		}
+	}
+}
+
+// parseNumberString converts a numeric literal into a KDLValue, keeping
+// integers as big.Int and decimals in their exact base 10 form.
+func parseNumberString(rawStr string) (KDLValue, error) {
+	var value KDLValue
+	base, err := validateNumber(rawStr)
+	if err != nil {
+		return value, err
+	}
+
+	str := strings.ReplaceAll(rawStr, "_", "")
+	if base == 0 {
+		dec, err := parseDecimal(str)
+		if err != nil {
+			return value, err
+		}
+		return newDecimalValue(dec), nil
+	}
+
+	digits := strings.TrimLeft(str, "+-")
+	if base != 10 {
+		digits = digits[2:]
+	}
+	integer, ok := new(big.Int).SetString(digits, base)
+	if !ok {
+		return value, invalidNumValueErr()
+	}
+	if str[0] == dash {
+		integer.Neg(integer)
+	}
+	return newIntegerValue(integer), nil
+}
+
+// validateNumber checks a numeric literal against the KDL number grammar and
+// returns its radix, or 0 if it is a decimal with a fraction or exponent.
+//
+//	decimal := sign? integer ('.' integer)? exponent?
+//	exponent := ('e' | 'E') sign? integer
+//	integer := digit (digit | '_')*
+//	hex := sign? '0x' hex-digit (hex-digit | '_')*
+//	octal := sign? '0o' [0-7] [0-7_]*
+//	binary := sign? '0b' ('0' | '1') ('0' | '1' | '_')*
+func validateNumber(str string) (int, error) {
+	i := 0
+	if i < len(str) && (str[i] == '+' || str[i] == dash) {
+		i++
+	}
+
+	if len(str) > i+1 && str[i] == '0' {
+		base := 0
+		switch str[i+1] {
+		case 'x':
+			base = 16
+		case 'o':
+			base = 8
+		case 'b':
+			base = 2
+		}
+		if base != 0 {
+			end := scanDigits(str, i+2, base)
+			if end == i+2 || end != len(str) {
+				return base, invalidNumValueErr()
+			}
+			return base, nil
+		}
+	}
+
+	end := scanDigits(str, i, 10)
+	if end == i {
+		return 10, invalidNumValueErr()
+	}
+	i = end
+	base := 10
+
+	if i < len(str) && str[i] == dot {
+		end = scanDigits(str, i+1, 10)
+		if end == i+1 {
+			return base, invalidNumValueErr()
+		}
+		i = end
+		base = 0
+	}
+
+	if i < len(str) && (str[i] == 'e' || str[i] == 'E') {
+		i++
+		if i < len(str) && (str[i] == '+' || str[i] == dash) {
+			i++
+		}
+		end = scanDigits(str, i, 10)
+		if end == i {
+			return base, invalidNumValueErr()
 		}
+		i = end
+		base = 0
+	}
+
+	if i != len(str) {
+		return base, invalidNumValueErr()
+	}
+	return base, nil
+}
+
+// scanDigits returns the index just past the run of digits in the given base
+// starting at start. The run has to start with a digit but may contain
+// underscores after that.
+func scanDigits(str string, start int, base int) int {
+	i := start
+	for ; i < len(str); i++ {
+		if str[i] == underscore && i > start {
+			continue
+		}
+		if !isDigit(rune(str[i]), base) {
+			break
+		}
+	}
+	return i
+}
+
+func isDigit(r rune, base int) bool {
+	switch base {
+	case 2:
+		return r == '0' || r == '1'
+	case 8:
+		return r >= '0' && r <= '7'
+	case 16:
+		return (r >= '0' && r <= '9') || (r >= 'a' && r <= 'f') || (r >= 'A' && r <= 'F')
 	}
+	return r >= '0' && r <= '9'
 }
 
 func parseNull(kdlr *kdlReader, key string) (KDLNull, error) {
@@ -0,0 +1,658 @@
+package kdlgo
+
+import (
+	"bufio"
+	"bytes"
+	"encoding"
+	"errors"
+	"io"
+	"math/big"
+	"reflect"
+	"strconv"
+)
+
+// Unmarshal parses data and stores the document in the value v points to. It
+// is the reverse of Marshal and uses the same `kdl` struct tags: nodes are
+// matched to child fields and map entries by name, arguments are assigned to
+// arg fields in order with any left over going to an args field, and
+// properties are matched to prop fields by key. If a property is repeated, the
+// last occurrence is used. Nodes that don't match any field are stored in a
+// children field if there is one.
+//
+// Numbers are checked against the range of the type they are stored in, and
+// null sets pointers, maps, slices and interfaces to nil while leaving other
+// values unchanged. Entries without a matching field are ignored unless the
+// Decoder is told to DisallowUnknownFields.
+//
+// Values implementing Unmarshaler, ValueUnmarshaler or
+// encoding.TextUnmarshaler read themselves, like they are written by Marshal.
+//
+// Values with a type annotation have to satisfy it according to
+// DefaultAnnotations. If the value the annotation converts them into can be
+// stored in the field, e.g. a `(date-time)` string in a time.Time or a `(url)`
+// in a *url.URL, it is stored as it is.
+//
+// Decoding into an empty interface stores the document as converted by
+// Document.ToGeneric.
+//
+// The error returned when a value doesn't fit is an *UnmarshalError naming the
+// path to the node and where it was in the input.
+func Unmarshal(data []byte, v interface{}) error {
+	return NewDecoder(bytes.NewReader(data)).Decode(v)
+}
+
+// Decoder reads a document from an io.Reader and decodes it like Unmarshal.
+type Decoder struct {
+	r                     io.Reader
+	opts                  []ParseOption
+	disallowUnknownFields bool
+	annotations           *AnnotationRegistry
+	done                  bool
+}
+
+// NewDecoder returns a Decoder that parses its input with the given options.
+func NewDecoder(r io.Reader, opts ...ParseOption) *Decoder {
+	return &Decoder{r: r, opts: opts, annotations: DefaultAnnotations}
+}
+
+// SetAnnotations changes the registry used to check and convert annotated
+// values from DefaultAnnotations to registry, or turns annotations off if it
+// is nil.
+func (dec *Decoder) SetAnnotations(registry *AnnotationRegistry) {
+	dec.annotations = registry
+}
+
+// DisallowUnknownFields makes Decode return an error for any node, argument or
+// property that doesn't have a field to be stored in.
+func (dec *Decoder) DisallowUnknownFields() {
+	dec.disallowUnknownFields = true
+}
+
+// Decode reads the whole input and stores it in the value v points to. As a
+// document can't be split, every call after the first returns io.EOF.
+func (dec *Decoder) Decode(v interface{}) error {
+	if dec.done {
+		return io.EOF
+	}
+	dec.done = true
+
+	doc, err := ParseDocumentReader(bufio.NewReader(dec.r), dec.opts...)
+	if err != nil {
+		return err
+	}
+	d := &decodeState{
+		disallowUnknownFields: dec.disallowUnknownFields,
+		annotations:           dec.annotations,
+	}
+	return d.document(doc, v)
+}
+
+type decodeState struct {
+	disallowUnknownFields bool
+	annotations           *AnnotationRegistry
+}
+
+func (d *decodeState) document(doc *Document, v interface{}) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() {
+		return &UnmarshalError{Kind: KDLUnsupportedType, Type: reflect.TypeOf(v)}
+	}
+
+	target := allocate(rv.Elem())
+	switch {
+	case target.Type() == documentType:
+		target.Set(reflect.ValueOf(*doc))
+		return nil
+	case target.Kind() == reflect.Interface && target.NumMethod() == 0:
+		target.Set(reflect.ValueOf(doc.ToGeneric()))
+		return nil
+	}
+	if target.Kind() == reflect.Slice {
+		return d.list(doc.nodes, "", target)
+	}
+	return d.node(&Node{children: doc.nodes}, "", target)
+}
+
+// node stores a node in v, which is done according to the type of v as
+// described for Marshal.
+func (d *decodeState) node(node *Node, path string, v reflect.Value) error {
+	if isNullNode(node) && isNilable(v) {
+		v.Set(reflect.Zero(v.Type()))
+		return nil
+	}
+
+	if len(node.args) == 1 && len(node.props) == 0 && len(node.children) == 0 {
+		if ok, err := d.annotated(node.args[0], path, v); ok || err != nil {
+			return err
+		}
+	}
+
+	v = allocate(v)
+	if u, ok := unmarshalerFor(v, unmarshalerType); ok {
+		err := u.(Unmarshaler).UnmarshalKDL(node)
+		if err != nil {
+			return d.wrapError(KDLMarshalerFailure, err, v.Type(), path, node.span)
+		}
+		return nil
+	}
+	if isNullNode(node) {
+		return d.value(node.args[0], path, v)
+	}
+
+	switch {
+	case v.Type() == nodeType:
+		v.Set(reflect.ValueOf(*node))
+		return nil
+	case v.Kind() == reflect.Interface && v.NumMethod() == 0:
+		return d.genericNode(node, path, v)
+	case isScalarType(v.Type()):
+		if len(node.args) != 1 || len(node.props) > 0 || len(node.children) > 0 {
+			return d.error(KDLTypeMismatch, v.Type(), path, node.span)
+		}
+		return d.value(node.args[0], path, v)
+	}
+
+	switch v.Kind() {
+	case reflect.Slice:
+		if !isScalarType(v.Type().Elem()) {
+			return d.list(node.children, path, v)
+		}
+		list := reflect.MakeSlice(v.Type(), len(node.args), len(node.args))
+		for i, arg := range node.args {
+			err := d.value(arg, path, list.Index(i))
+			if err != nil {
+				return err
+			}
+		}
+		v.Set(list)
+		return nil
+	case reflect.Struct:
+		return d.fields(node, path, v)
+	case reflect.Map:
+		return d.mapChildren(node.children, path, v)
+	}
+	return d.error(KDLUnsupportedType, v.Type(), path, node.span)
+}
+
+// genericNode stores a node in an empty interface. Nodes holding nothing but
+// arguments are stored as their only argument or a slice of all of them, and
+// any other node as converted by Node.ToGeneric.
+func (d *decodeState) genericNode(node *Node, path string, v reflect.Value) error {
+	if len(node.props) > 0 || len(node.children) > 0 {
+		v.Set(reflect.ValueOf(node.ToGeneric()))
+		return nil
+	}
+	if len(node.args) == 1 {
+		return d.value(node.args[0], path, v)
+	}
+
+	list := make([]interface{}, len(node.args))
+	for i, arg := range node.args {
+		err := d.value(arg, path, reflect.ValueOf(&list[i]).Elem())
+		if err != nil {
+			return err
+		}
+	}
+	v.Set(reflect.ValueOf(list))
+	return nil
+}
+
+// list stores every node in an element of the slice v.
+func (d *decodeState) list(nodes []*Node, path string, v reflect.Value) error {
+	list := reflect.MakeSlice(v.Type(), len(nodes), len(nodes))
+	for i, node := range nodes {
+		err := d.node(node, childPath(path, node.name), list.Index(i))
+		if err != nil {
+			return err
+		}
+	}
+	v.Set(list)
+	return nil
+}
+
+// fields stores the entries and children of a node in the fields of a struct.
+func (d *decodeState) fields(node *Node, path string, v reflect.Value) error {
+	fields, err := structFields(v.Type())
+	if err != nil {
+		return d.tagError(err, path, node.span)
+	}
+
+	var argFields []structField
+	var argsField, childrenField *structField
+	propFields := map[string]structField{}
+	childFields := map[string]structField{}
+	for i, field := range fields {
+		switch field.tag.kind {
+		case tagArg:
+			argFields = append(argFields, field)
+		case tagArgs:
+			argsField = &fields[i]
+		case tagProp:
+			propFields[field.tag.name] = field
+		case tagChildren:
+			childrenField = &fields[i]
+		default:
+			childFields[field.tag.name] = field
+		}
+	}
+
+	for i, arg := range node.args {
+		var err error
+		switch {
+		case i < len(argFields):
+			err = d.value(arg, path, fieldByIndexAlloc(v, argFields[i].index))
+		case argsField == nil && d.disallowUnknownFields:
+			err = d.error(KDLUnknownField, nil, path, arg.span)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	if argsField != nil {
+		rest := node.args[minInt(len(argFields), len(node.args)):]
+		err := d.node(&Node{name: node.name, args: rest, span: node.span}, path, fieldByIndexAlloc(v, argsField.index))
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, prop := range node.props {
+		field, ok := propFields[prop.key]
+		if !ok {
+			if d.disallowUnknownFields {
+				return d.error(KDLUnknownField, nil, path, prop.span)
+			}
+			continue
+		}
+		err := d.value(prop.value, path, fieldByIndexAlloc(v, field.index))
+		if err != nil {
+			return err
+		}
+	}
+
+	// Children that go into a list are collected first so that the list only
+	// has to be built once.
+	var rest []*Node
+	lists := map[string][]*Node{}
+	var listNames []string
+	for _, child := range node.children {
+		field, ok := childFields[child.name]
+		if !ok {
+			rest = append(rest, child)
+			continue
+		}
+		fv := fieldByIndexAlloc(v, field.index)
+		if isNodeList(fv.Type()) {
+			if _, ok := lists[child.name]; !ok {
+				listNames = append(listNames, child.name)
+			}
+			lists[child.name] = append(lists[child.name], child)
+			continue
+		}
+		err := d.node(child, childPath(path, child.name), fv)
+		if err != nil {
+			return err
+		}
+	}
+	for _, name := range listNames {
+		fv := allocate(fieldByIndexAlloc(v, childFields[name].index))
+		err := d.list(lists[name], path, fv)
+		if err != nil {
+			return err
+		}
+	}
+
+	if childrenField != nil {
+		return d.node(&Node{name: node.name, children: rest, span: node.span}, path, fieldByIndexAlloc(v, childrenField.index))
+	}
+	if len(rest) > 0 && d.disallowUnknownFields {
+		return d.error(KDLUnknownField, nil, childPath(path, rest[0].name), rest[0].span)
+	}
+	return nil
+}
+
+// mapChildren stores every node in the map v under its name. If the values of
+// the map are lists of nodes, all the nodes with the same name are stored in
+// one list.
+func (d *decodeState) mapChildren(nodes []*Node, path string, v reflect.Value) error {
+	if v.IsNil() {
+		v.Set(reflect.MakeMap(v.Type()))
+	}
+
+	elemType := v.Type().Elem()
+	lists := map[string][]*Node{}
+	var listNames []string
+	for _, node := range nodes {
+		if isNodeList(elemType) {
+			if _, ok := lists[node.name]; !ok {
+				listNames = append(listNames, node.name)
+			}
+			lists[node.name] = append(lists[node.name], node)
+			continue
+		}
+
+		key, err := d.mapKey(node.name, path, node.span, v.Type())
+		if err != nil {
+			return err
+		}
+		elem := reflect.New(elemType).Elem()
+		err = d.node(node, childPath(path, node.name), elem)
+		if err != nil {
+			return err
+		}
+		v.SetMapIndex(key, elem)
+	}
+
+	for _, name := range listNames {
+		key, err := d.mapKey(name, path, lists[name][0].span, v.Type())
+		if err != nil {
+			return err
+		}
+		elem := reflect.New(elemType).Elem()
+		err = d.list(lists[name], path, allocate(elem))
+		if err != nil {
+			return err
+		}
+		v.SetMapIndex(key, elem)
+	}
+	return nil
+}
+
+func (d *decodeState) mapKey(name string, path string, span Span, mapType reflect.Type) (reflect.Value, error) {
+	keyType := mapType.Key()
+	key := reflect.New(keyType).Elem()
+	switch keyType.Kind() {
+	case reflect.String:
+		key.SetString(name)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(name, 10, keyType.Bits())
+		if err != nil {
+			return key, d.error(KDLTypeMismatch, keyType, childPath(path, name), span)
+		}
+		key.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		n, err := strconv.ParseUint(name, 10, keyType.Bits())
+		if err != nil {
+			return key, d.error(KDLTypeMismatch, keyType, childPath(path, name), span)
+		}
+		key.SetUint(n)
+	default:
+		return key, d.error(KDLUnsupportedType, mapType, path, span)
+	}
+	return key, nil
+}
+
+// value stores a single value in v.
+func (d *decodeState) value(value KDLValue, path string, v reflect.Value) error {
+	if value.Type == KDLNullType && isNilable(v) {
+		v.Set(reflect.Zero(v.Type()))
+		return nil
+	}
+
+	if ok, err := d.annotated(value, path, v); ok || err != nil {
+		return err
+	}
+
+	v = allocate(v)
+	switch v.Type() {
+	case valueType:
+		v.Set(reflect.ValueOf(value))
+		return nil
+	case bigIntType:
+		if value.Type == KDLNullType {
+			return nil
+		}
+		integer, kind := integerValue(value)
+		if len(kind) > 0 {
+			return d.error(kind, v.Type(), path, value.span)
+		}
+		v.Set(reflect.ValueOf(integer).Elem())
+		return nil
+	}
+
+	if u, ok := unmarshalerFor(v, valueUnmarshalerType); ok {
+		err := u.(ValueUnmarshaler).UnmarshalKDLValue(value)
+		if err != nil {
+			return d.wrapError(KDLMarshalerFailure, err, v.Type(), path, value.span)
+		}
+		return nil
+	}
+	if value.Type == KDLNullType {
+		return nil
+	}
+	if u, ok := unmarshalerFor(v, textUnmarshalerType); ok {
+		text, err := value.ToString()
+		if err != nil || (value.Type != KDLStringType && value.Type != KDLRawStringType) {
+			return d.error(KDLTypeMismatch, v.Type(), path, value.span)
+		}
+		err = u.(encoding.TextUnmarshaler).UnmarshalText([]byte(text))
+		if err != nil {
+			return d.wrapError(KDLMarshalerFailure, err, v.Type(), path, value.span)
+		}
+		return nil
+	}
+
+	mismatch := d.error(KDLTypeMismatch, v.Type(), path, value.span)
+	outOfRange := d.error(KDLNumberOutOfRange, v.Type(), path, value.span)
+	switch v.Kind() {
+	case reflect.Bool:
+		if value.Type != KDLBoolType {
+			return mismatch
+		}
+		v.SetBool(value.Bool)
+	case reflect.String:
+		switch value.Type {
+		case KDLStringType:
+			v.SetString(value.String)
+		case KDLRawStringType:
+			v.SetString(value.RawString)
+		default:
+			return mismatch
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		integer, kind := integerValue(value)
+		if kind == KDLTypeMismatch {
+			return mismatch
+		}
+		if kind == KDLNumberOutOfRange || !integer.IsInt64() || v.OverflowInt(integer.Int64()) {
+			return outOfRange
+		}
+		v.SetInt(integer.Int64())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		integer, kind := integerValue(value)
+		if kind == KDLTypeMismatch {
+			return mismatch
+		}
+		if kind == KDLNumberOutOfRange || !integer.IsUint64() || v.OverflowUint(integer.Uint64()) {
+			return outOfRange
+		}
+		v.SetUint(integer.Uint64())
+	case reflect.Float32, reflect.Float64:
+		if value.Type != KDLNumberType {
+			return mismatch
+		}
+		f, ok := fitFloat(value, v.Type().Bits())
+		if !ok {
+			return outOfRange
+		}
+		v.SetFloat(f)
+	case reflect.Interface:
+		if v.NumMethod() > 0 {
+			return d.error(KDLUnsupportedType, v.Type(), path, value.span)
+		}
+		v.Set(reflect.ValueOf(genericValue(value)))
+	default:
+		return d.error(KDLUnsupportedType, v.Type(), path, value.span)
+	}
+	return nil
+}
+
+func (d *decodeState) error(kind KDLErrorType, t reflect.Type, path string, span Span) error {
+	return &UnmarshalError{
+		Kind:   kind,
+		Type:   t,
+		Path:   path,
+		Line:   span.Start.Line,
+		Column: span.Start.Column,
+	}
+}
+
+// annotated checks a value against its type annotation and stores the value
+// it converts into in v if v can hold it, reporting whether it did.
+func (d *decodeState) annotated(value KDLValue, path string, v reflect.Value) (bool, error) {
+	if d.annotations == nil || len(value.declaredType) == 0 {
+		return false, nil
+	}
+	converted, err := d.annotations.Convert(value)
+	if err != nil {
+		return false, d.wrapError(KDLInvalidAnnotation, errors.Unwrap(err), v.Type(), path, value.span)
+	}
+	return converted != nil && setConverted(v, reflect.ValueOf(converted)), nil
+}
+
+// wrapError returns an *UnmarshalError of the given kind wrapping err.
+func (d *decodeState) wrapError(kind KDLErrorType, err error, t reflect.Type, path string, span Span) error {
+	return &UnmarshalError{
+		Kind:   kind,
+		Type:   t,
+		Path:   path,
+		Line:   span.Start.Line,
+		Column: span.Start.Column,
+		Err:    err,
+	}
+}
+
+// tagError turns the error for an invalid struct tag into an *UnmarshalError.
+func (d *decodeState) tagError(err error, path string, span Span) error {
+	var marshalErr *MarshalError
+	if !errors.As(err, &marshalErr) {
+		return err
+	}
+	return d.error(marshalErr.Kind, marshalErr.Type, childPath(path, marshalErr.Field), span)
+}
+
+// genericValue returns the Go value closest to a KDLValue: a bool, string,
+// nil, int64, *big.Int for integers that don't fit in one, or float64.
+func genericValue(value KDLValue) interface{} {
+	switch value.Type {
+	case KDLBoolType:
+		return value.Bool
+	case KDLStringType:
+		return value.String
+	case KDLRawStringType:
+		return value.RawString
+	case KDLNumberType:
+		if n, ok := value.Int64(); ok {
+			return n
+		}
+		if n, ok := value.BigInt(); ok {
+			return n
+		}
+		return value.Float64()
+	}
+	return nil
+}
+
+// integerValue returns the value as an integer if it is a number without a
+// fractional part, even if it was written as a float. Otherwise, it returns
+// why it isn't one: KDLTypeMismatch, or KDLNumberOutOfRange if it is a decimal
+// too large for BigRat.
+func integerValue(value KDLValue) (*big.Int, KDLErrorType) {
+	if integer, ok := value.BigInt(); ok {
+		return integer, ""
+	}
+	rat, ok := value.BigRat()
+	if !ok && value.decimal != nil && value.decimal.exponent > value.decimal.scale {
+		return nil, KDLNumberOutOfRange
+	}
+	if !ok || !rat.IsInt() {
+		return nil, KDLTypeMismatch
+	}
+	return new(big.Int).Set(rat.Num()), ""
+}
+
+// isNullNode reports whether a node holds nothing but a single null, which is
+// how Marshal writes nil pointers, maps and slices.
+func isNullNode(node *Node) bool {
+	return len(node.args) == 1 && node.args[0].Type == KDLNullType &&
+		len(node.props) == 0 && len(node.children) == 0
+}
+
+// setConverted stores the value an annotation was converted into in v, or in
+// what v points to, reporting whether its type allowed it.
+func setConverted(v reflect.Value, converted reflect.Value) bool {
+	for {
+		if converted.Type().AssignableTo(v.Type()) {
+			v.Set(converted)
+			return true
+		}
+		if v.Kind() != reflect.Ptr || !converted.Type().AssignableTo(indirectType(v.Type())) {
+			return false
+		}
+		if v.IsNil() {
+			v.Set(reflect.New(v.Type().Elem()))
+		}
+		v = v.Elem()
+	}
+}
+
+// isNilable reports whether null can be stored in v by setting it to nil.
+func isNilable(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Interface, reflect.Map, reflect.Slice:
+		return true
+	}
+	return false
+}
+
+// isNodeList reports whether values of type t are lists written as one node
+// per element.
+func isNodeList(t reflect.Type) bool {
+	t = indirectType(t)
+	return t.Kind() == reflect.Slice && !isScalarType(t.Elem())
+}
+
+func indirectType(t reflect.Type) reflect.Type {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	return t
+}
+
+// allocate follows pointers, allocating any that are nil, until it reaches a
+// value that isn't one.
+func allocate(v reflect.Value) reflect.Value {
+	for v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			v.Set(reflect.New(v.Type().Elem()))
+		}
+		v = v.Elem()
+	}
+	return v
+}
+
+// fieldByIndexAlloc is like reflect.Value.FieldByIndex, but allocates any nil
+// embedded pointers on the way.
+func fieldByIndexAlloc(v reflect.Value, index []int) reflect.Value {
+	for i, x := range index {
+		if i > 0 {
+			v = allocate(v)
+		}
+		v = v.Field(x)
+	}
+	return v
+}
+
+func childPath(path string, name string) string {
+	if len(path) == 0 {
+		return name
+	}
+	return path + " > " + name
+}
+
+func minInt(a int, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
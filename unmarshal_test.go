@@ -0,0 +1,194 @@
+package kdlgo
+
+import (
+	"errors"
+	"io"
+	"math"
+	"math/big"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestUnmarshal(t *testing.T) {
+	input := `version 2
+ratio 0.1
+name "example \"config\""
+server "localhost" "local" "loopback" port=8080 port=8081 {
+    headers {
+        a "1"
+        b "2"
+    }
+    route "/" {
+        method "GET" "HEAD"
+    }
+    route "/submit"
+}
+server "0.0.0.0" port=443 tls=true
+debug true
+list 1 "two" null
+big 1180591620717411303424
+missing null
+`
+	var config marshalConfig
+	err := Unmarshal([]byte(input), &config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := marshalConfig{
+		MarshalMeta: MarshalMeta{Version: 2, Ratio: 0.1},
+		Name:        "example \"config\"",
+		Servers: []marshalServer{
+			{
+				Host:    "localhost",
+				Port:    8081,
+				Aliases: []string{"local", "loopback"},
+				Headers: map[string]string{"a": "1", "b": "2"},
+				Routes: []marshalRoute{
+					{Path: "/", Method: []string{"GET", "HEAD"}},
+					{Path: "/submit"},
+				},
+			},
+			{Host: "0.0.0.0", Port: 443, TLS: true, Aliases: []string{}},
+		},
+		Extra: map[string]interface{}{
+			"debug": true,
+			"list":  []interface{}{int64(1), "two", nil},
+		},
+		Big: new(big.Int).Lsh(big.NewInt(1), 70),
+	}
+	if !reflect.DeepEqual(config, expected) {
+		t.Errorf("Expected:\n%#v\nGot:\n%#v", expected, config)
+	}
+}
+
+func TestUnmarshalRoundTrip(t *testing.T) {
+	type item struct {
+		Name  string  `kdl:",arg"`
+		Price float64 `kdl:"price,prop"`
+	}
+	type inventory struct {
+		Items  []item          `kdl:"item"`
+		Counts map[string]uint `kdl:"counts"`
+		Notes  []string        `kdl:"notes"`
+		Owner  *string         `kdl:"owner"`
+	}
+
+	owner := "someone"
+	original := inventory{
+		Items:  []item{{"apple", 0.5}, {"pear", 1.25}},
+		Counts: map[string]uint{"apple": 3, "pear": 0},
+		Notes:  []string{"fresh", "local"},
+		Owner:  &owner,
+	}
+	data, err := Marshal(original)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded inventory
+	err = Unmarshal(data, &decoded)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(original, decoded) {
+		t.Errorf("Expected:\n%#v\nGot:\n%#v", original, decoded)
+	}
+
+	var list []item
+	err = Unmarshal([]byte("- \"a\" price=1\n- \"b\"\n"), &list)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(list, []item{{"a", 1}, {"b", 0}}) {
+		t.Errorf("Unexpected list: %#v", list)
+	}
+}
+
+func TestUnmarshalErrors(t *testing.T) {
+	type port struct {
+		Number uint16 `kdl:",arg"`
+	}
+	type server struct {
+		Port port   `kdl:"port"`
+		Name string `kdl:"name,prop"`
+	}
+	type config struct {
+		Servers []server `kdl:"server"`
+		Level   int8     `kdl:"level"`
+		Ratio   float64  `kdl:"ratio"`
+		Scale   float32  `kdl:"scale"`
+	}
+
+	tests := []struct {
+		input  string
+		kind   KDLErrorType
+		path   string
+		line   int
+		column int
+	}{
+		{"server {\n    port 65536\n}", KDLNumberOutOfRange, "server > port", 2, 10},
+		{"server {\n    port -1\n}", KDLNumberOutOfRange, "server > port", 2, 10},
+		{"server {\n    port 1.5\n}", KDLTypeMismatch, "server > port", 2, 10},
+		{"server name=1", KDLTypeMismatch, "server", 1, 13},
+		{"level \"high\"", KDLTypeMismatch, "level", 1, 7},
+		{"level 1 2", KDLTypeMismatch, "level", 1, 1},
+		{"level 1e99999999", KDLNumberOutOfRange, "level", 1, 7},
+		{"level 1e-99999999", KDLTypeMismatch, "level", 1, 7},
+		{"ratio 1e400", KDLNumberOutOfRange, "ratio", 1, 7},
+		{"ratio 1e999999999", KDLNumberOutOfRange, "ratio", 1, 7},
+		{"ratio 1e2147483647", KDLNumberOutOfRange, "ratio", 1, 7},
+		{"scale 1e39", KDLNumberOutOfRange, "scale", 1, 7},
+		{"server\nserver {\n    port \"80\"\n}", KDLTypeMismatch, "server > port", 3, 10},
+	}
+	for _, test := range tests {
+		var c config
+		err := Unmarshal([]byte(test.input), &c)
+		var unmarshalErr *UnmarshalError
+		if !errors.As(err, &unmarshalErr) {
+			t.Errorf("Expected an *UnmarshalError for %q, got %v", test.input, err)
+			continue
+		}
+		if !errors.Is(err, test.kind) || unmarshalErr.Path != test.path ||
+			unmarshalErr.Line != test.line || unmarshalErr.Column != test.column {
+			t.Errorf("Unexpected error for %q: %v (path %q)", test.input, err, unmarshalErr.Path)
+		}
+	}
+
+	var c config
+	dec := NewDecoder(strings.NewReader("ratio #-inf\nscale #inf"), WithVersion(KDLVersion2))
+	if err := dec.Decode(&c); err != nil || !math.IsInf(c.Ratio, -1) || !math.IsInf(float64(c.Scale), 1) {
+		t.Errorf("Expected #inf and #-inf to be decoded, got %v, %v, %v", c.Ratio, c.Scale, err)
+	}
+	if err := Unmarshal([]byte("level 1"), c); err == nil {
+		t.Error("Expected an error when not decoding into a pointer")
+	}
+}
+
+func TestDecoder(t *testing.T) {
+	type config struct {
+		Name string `kdl:"name"`
+	}
+
+	dec := NewDecoder(strings.NewReader("name \"a\"\nunknown 1"))
+	var c config
+	if err := dec.Decode(&c); err != nil || c.Name != "a" {
+		t.Fatalf("Unexpected result %#v, %v", c, err)
+	}
+	if err := dec.Decode(&c); err != io.EOF {
+		t.Error("Expected io.EOF after the document was decoded, got", err)
+	}
+
+	dec = NewDecoder(strings.NewReader("name \"a\"\nextra 1"))
+	dec.DisallowUnknownFields()
+	err := dec.Decode(&c)
+	if !errors.Is(err, KDLUnknownField) {
+		t.Error("Expected an unknown field error, got", err)
+	}
+
+	dec = NewDecoder(strings.NewReader("/- kdl-version 2\nname #null"), WithVersion(KDLVersionAuto))
+	c.Name = "unchanged"
+	if err := dec.Decode(&c); err != nil || c.Name != "unchanged" {
+		t.Errorf("Unexpected result %#v, %v", c, err)
+	}
+}
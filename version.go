@@ -0,0 +1,248 @@
+package kdlgo
+
+import (
+	"bufio"
+	"bytes"
+	"io/ioutil"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// versionMarker matches the `/- kdl-version` marker a document may start with
+// to declare the version of KDL it is written in.
+var versionMarker = regexp.MustCompile(`^(?:\x{FEFF})?[\t ]*/-[\t ]*kdl-version[\t ]+([12])(?:[\t ;\r\n]|$)`)
+
+// maxVersionMarker is how far into the input detectVersion looks.
+const maxVersionMarker = 64
+
+func (kdlr *kdlReader) isVersion2() bool {
+	return kdlr.options.version == KDLVersion2
+}
+
+// detectVersion returns the version declared by the marker at the start of
+// the input, or KDLVersionAuto if there is none.
+func detectVersion(reader *bufio.Reader) KDLVersion {
+	start, _ := reader.Peek(maxVersionMarker)
+	match := versionMarker.FindSubmatch(start)
+	if match == nil {
+		return KDLVersionAuto
+	}
+	if match[1][0] == '2' {
+		return KDLVersion2
+	}
+	return KDLVersion1
+}
+
+// parseAnyVersion parses a document as the version declared by its marker.
+// Without one, the document is parsed as KDL 2.0 and then as KDL 1.0 if that
+// fails, in which case the KDL 2.0 errors are returned if both fail.
+func parseAnyVersion(reader *bufio.Reader, options parseOptions) (*Document, error) {
+	options.version = detectVersion(reader)
+	if options.version != KDLVersionAuto {
+		kdlr := newKDLReader(reader)
+		kdlr.options = options
+		return parseDocument(kdlr)
+	}
+
+	data, err := ioutil.ReadAll(reader)
+	if err != nil {
+		return nil, wrapError(newKDLReader(reader), err)
+	}
+
+	options.version = KDLVersion2
+	kdlr := newKDLReader(bufio.NewReader(bytes.NewReader(data)))
+	kdlr.options = options
+	doc, err := parseDocument(kdlr)
+	if err == nil {
+		return doc, nil
+	}
+
+	options.version = KDLVersion1
+	kdlr = newKDLReader(bufio.NewReader(bytes.NewReader(data)))
+	kdlr.options = options
+	v1Doc, v1Err := parseDocument(kdlr)
+	if v1Err == nil {
+		return v1Doc, nil
+	}
+	return doc, err
+}
+
+// skipSlashdashSpace discards the space after a slashdash, which may span
+// multiple lines in KDL 2.0.
+func skipSlashdashSpace(kdlr *kdlReader) error {
+	if kdlr.isVersion2() {
+		return skipLineSpace(kdlr)
+	}
+	_, err := skipNodeSpace(kdlr)
+	return err
+}
+
+// isPropertyNext consumes the equals sign of a property if it comes next.
+// KDL 2.0 allows whitespace before it, which is only consumed along with it.
+func isPropertyNext(kdlr *kdlReader) bool {
+	if !kdlr.isVersion2() {
+		isProp, _ := kdlr.isNext([]byte{equals})
+		return isProp
+	}
+
+	length := 0
+	for {
+		peek, _ := kdlr.peekX(length + utf8.UTFMax)
+		if len(peek) <= length {
+			return false
+		}
+		r, size := utf8.DecodeRune(peek[length:])
+		if r == equals {
+			kdlr.discard(length + 1)
+			return true
+		}
+		if !isWhitespace(r) {
+			return false
+		}
+		length += size
+	}
+}
+
+// keywordValue returns the value of a KDL 2.0 keyword without its leading
+// '#'.
+func keywordValue(keyword string) (KDLValue, error) {
+	switch keyword {
+	case "true":
+		return NewKDLBool("", true).GetValue(), nil
+	case "false":
+		return NewKDLBool("", false).GetValue(), nil
+	case "null":
+		return NewKDLNull("").GetValue(), nil
+	case "inf":
+		return newInfValue(false), nil
+	case "-inf":
+		return newInfValue(true), nil
+	case "nan":
+		return newNaNValue(), nil
+	}
+	return KDLValue{}, invalidSyntaxErr()
+}
+
+// isValidIdentifierV2 reports whether token can be used as a bare identifier
+// in KDL 2.0, where it may be neither a keyword nor look like a number.
+func isValidIdentifierV2(token string) bool {
+	switch token {
+	case "", "true", "false", "null", "inf", "-inf", "nan":
+		return false
+	}
+
+	if token[0] == '+' || token[0] == dash {
+		token = token[1:]
+	}
+	if len(token) > 0 && token[0] == dot {
+		token = token[1:]
+	}
+	return len(token) == 0 || !isDigit(rune(token[0]), 10)
+}
+
+// recreateKeyV2 is recreateKey for KDL 2.0.
+func recreateKeyV2(key string) string {
+	if !isValidIdentifierV2(key) {
+		return RecreateString(key)
+	}
+	for _, r := range key {
+		if isWhitespace(r) || isNewline(r) || needsUnicodeEscape(r) ||
+			strings.ContainsRune(nonIdentifierCharsV2, r) {
+			return RecreateString(key)
+		}
+	}
+	return key
+}
+
+// recreateKDLV2 is KDLValue.RecreateKDL for KDL 2.0, which writes keywords
+// with a leading '#', including infinite and NaN numbers, and raw strings
+// without the 'r'.
+func recreateKDLV2(value KDLValue) (string, error) {
+	var s string
+	switch value.Type {
+	case KDLBoolType:
+		s = "#" + strconv.FormatBool(value.Bool)
+	case KDLNullType:
+		s = "#null"
+	case KDLNumberType:
+		s = value.NumberString()
+	case KDLRawStringType:
+		s = recreateRawStringV2(value.RawString)
+	default:
+		var err error
+		s, err = value.recreateKDLValue()
+		if err != nil {
+			return "", err
+		}
+	}
+	if len(value.declaredType) > 0 {
+		s = "(" + recreateKeyV2(value.declaredType) + ")" + s
+	}
+	return s, nil
+}
+
+// recreateRawStringV2 writes s as a KDL 2.0 raw string, or as a quoted string
+// if it has characters that can only be written escaped.
+func recreateRawStringV2(s string) string {
+	for _, r := range s {
+		if needsUnicodeEscape(r) {
+			return RecreateString(s)
+		}
+	}
+	hashes := "#"
+	for strings.Contains(s, "\""+hashes) {
+		hashes += "#"
+	}
+	return hashes + "\"" + s + "\"" + hashes
+}
+
+// dedentMultiLineString removes the indentation from the body of a KDL 2.0
+// multi-line string. The body starts with the newline after the opening
+// quotes and ends with the whitespace before the closing quotes, which is the
+// indentation every other non-blank line has to start with.
+func dedentMultiLineString(body string) (string, error) {
+	lines := splitLines(body)
+	indent := lines[len(lines)-1]
+	if len(lines) < 2 || !isBlank(indent) {
+		return "", invalidSyntaxErr()
+	}
+
+	lines = lines[1 : len(lines)-1]
+	for i, line := range lines {
+		switch {
+		case isBlank(line):
+			lines[i] = ""
+		case strings.HasPrefix(line, indent):
+			lines[i] = line[len(indent):]
+		default:
+			return "", invalidSyntaxErr()
+		}
+	}
+	return strings.Join(lines, "\n"), nil
+}
+
+func splitLines(s string) []string {
+	var lines []string
+	var line strings.Builder
+	var prev rune
+	for _, r := range s {
+		if r == newline && prev == '\r' {
+			prev = r
+			continue
+		}
+		if isNewline(r) {
+			lines = append(lines, line.String())
+			line.Reset()
+		} else {
+			line.WriteRune(r)
+		}
+		prev = r
+	}
+	return append(lines, line.String())
+}
+
+func isBlank(s string) bool {
+	return strings.TrimFunc(s, isWhitespace) == ""
+}
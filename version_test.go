@@ -0,0 +1,150 @@
+package kdlgo
+
+import (
+	"errors"
+	"math"
+	"testing"
+)
+
+func TestParseVersion2(t *testing.T) {
+	doc, err := ParseDocumentString(`node #true #null #inf #-inf #nan bare key = value
+(type) typed ( u8 ) 12 #"raw \n"# ##"quote"#"##
+multi """
+    hello
+      world\s
+    """ "esc\
+         aped" {
+    child
+} /- {
+    skipped
+}
+/-
+commented
+`, WithVersion(KDLVersion2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if doc.GetVersion() != KDLVersion2 {
+		t.Error("Document should be parsed as KDL 2.0")
+	}
+
+	nodes := doc.GetNodes()
+	if len(nodes) != 3 {
+		t.Fatalf("There should be 3 nodes. Got %d instead.", len(nodes))
+	}
+
+	args := nodes[0].GetArgs()
+	if len(args) != 6 {
+		t.Fatalf("Expected 6 arguments but got %d", len(args))
+	}
+	if args[0].Type != KDLBoolType || !args[0].Bool || args[1].Type != KDLNullType {
+		t.Error("Keywords are incorrectly parsed")
+	}
+	if !math.IsInf(args[2].Float64(), 1) || !math.IsInf(args[3].Float64(), -1) || !math.IsNaN(args[4].Float64()) {
+		t.Error("Non-finite numbers are incorrectly parsed")
+	}
+	if args[4].NumberString() != "#nan" {
+		t.Error("Expected #nan but got " + args[4].NumberString())
+	}
+	if args[5].Type != KDLStringType || args[5].String != "bare" {
+		t.Error("Bare identifiers should be parsed as strings")
+	}
+	props := nodes[0].GetProps()
+	if len(props) != 1 || props[0].GetKey() != "key" || props[0].GetValue().String != "value" {
+		t.Error("Property with spaces around '=' is incorrectly parsed")
+	}
+
+	typed := nodes[1]
+	if typed.GetDeclaredType() != "type" || typed.GetArgs()[0].GetDeclaredType() != "u8" {
+		t.Error("Type annotations with spaces are incorrectly parsed")
+	}
+	if typed.GetArgs()[1].RawString != `raw \n` || typed.GetArgs()[2].RawString != `quote"#` {
+		t.Error("Raw strings are incorrectly parsed")
+	}
+
+	multi := nodes[2]
+	if multi.GetArgs()[0].String != "hello\n  world " {
+		t.Errorf("Multi-line string is incorrectly dedented: %q", multi.GetArgs()[0].String)
+	}
+	if multi.GetArgs()[1].String != "escaped" {
+		t.Errorf("Escaped whitespace should be removed: %q", multi.GetArgs()[1].String)
+	}
+	if len(multi.GetChildren()) != 1 {
+		t.Error("Slashdashed children should be skipped")
+	}
+}
+
+func TestFormatVersion2(t *testing.T) {
+	input := "node #true #null #inf #-inf #nan (u8)1 #\"raw\"# key=\"inf\"\n"
+	doc, err := ParseDocumentString(input, WithVersion(KDLVersion2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := doc.Format(FormatOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s != input {
+		t.Errorf("Expected %q but got %q", input, s)
+	}
+
+	_, err = NewDocument(doc.GetNodes()...).Format(FormatOptions{})
+	if !errors.Is(err, KDLNonFiniteNumber) {
+		t.Error("Expected KDL 1.0 to refuse infinite numbers, got", err)
+	}
+}
+
+func TestParseVersion2Invalid(t *testing.T) {
+	invalid := []string{
+		`node true`,
+		`node r"raw"`,
+		`node .5`,
+		`node a#b`,
+		`node #yes`,
+		"node \"single\nline\"",
+		"node \"\"\"no newline\"\"\"",
+		"node \"\"\"\n  bad\n indent\n  \"\"\"",
+		`node {} {}`,
+		`node {} arg`,
+		`node /- {} arg`,
+	}
+	for _, kdl := range invalid {
+		_, err := ParseDocumentString(kdl, WithVersion(KDLVersion2))
+		if !errors.Is(err, KDLInvalidSyntax) {
+			t.Errorf("Expected %q to be invalid syntax but got %v", kdl, err)
+		}
+	}
+}
+
+func TestParseVersionAuto(t *testing.T) {
+	tests := []struct {
+		kdl      string
+		expected KDLVersion
+	}{
+		{"/- kdl-version 1\nnode true", KDLVersion1},
+		{"/- kdl-version 2\nnode #true", KDLVersion2},
+		{"node #true", KDLVersion2},
+		{"node true r\"raw\"", KDLVersion1},
+		{"node", KDLVersion2},
+	}
+	for _, test := range tests {
+		doc, err := ParseDocumentString(test.kdl, WithVersion(KDLVersionAuto))
+		if err != nil {
+			t.Errorf("%q: %v", test.kdl, err)
+			continue
+		}
+		if doc.GetVersion() != test.expected {
+			t.Errorf("%q: expected version %d but got %d", test.kdl, test.expected, doc.GetVersion())
+		}
+	}
+
+	_, err := ParseDocumentString("/- kdl-version 2\nnode true", WithVersion(KDLVersionAuto))
+	if err == nil {
+		t.Error("The version marker should be followed instead of falling back to KDL 1.0")
+	}
+
+	doc, err := ParseDocumentString("node true")
+	if err != nil || doc.GetVersion() != KDLVersion1 {
+		t.Error("Documents should be parsed as KDL 1.0 by default")
+	}
+}
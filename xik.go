@@ -0,0 +1,344 @@
+package kdlgo
+
+import (
+	"bytes"
+	"encoding/xml"
+	"io"
+	"regexp"
+	"strings"
+)
+
+// The names XML-in-KDL gives to nodes that aren't elements. Processing
+// instructions are named "?" followed by their target and directives "!"
+// followed by their keyword in lower case, e.g. "?xml" and "!doctype".
+const (
+	XiKText    = "-"
+	XiKComment = "!"
+)
+
+// XMLToXiK converts an XML document into a KDL document following the
+// XML-in-KDL (XiK) microsyntax:
+//
+//	<a href="/">Home</a>     a "Home" href="/"
+//	<p>Hi <b>there</b></p>   p { - "Hi "; b "there"; }
+//	<![CDATA[x < y]]>        - r"x < y"
+//	<!-- comment -->         ! " comment "
+//	<?xml version="1.0"?>    ?xml version="1.0"
+//	<!DOCTYPE html>          !doctype "html"
+//
+// Names keep their namespace prefixes and xmlns attributes are kept as
+// properties. Whitespace between elements is dropped.
+func XMLToXiK(data []byte) (*Document, error) {
+	dec := xml.NewDecoder(bytes.NewReader(data))
+	root := NewNode("")
+	stack := []*Node{root}
+	offset := int64(0)
+	for {
+		token, err := dec.RawToken()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		parent := stack[len(stack)-1]
+
+		switch t := token.(type) {
+		case xml.StartElement:
+			node := NewNode(xmlName(t.Name))
+			for _, attr := range t.Attr {
+				node.AddProp(xmlName(attr.Name), KDLValue{String: attr.Value, Type: KDLStringType})
+			}
+			parent.AddChild(node)
+			stack = append(stack, node)
+		case xml.EndElement:
+			if len(stack) == 1 || xmlName(t.Name) != parent.name {
+				return nil, &xml.SyntaxError{Msg: "unexpected end element </" + xmlName(t.Name) + ">"}
+			}
+			collapseXiKText(parent)
+			stack = stack[:len(stack)-1]
+		case xml.CharData:
+			text := KDLValue{String: string(t), Type: KDLStringType}
+			if bytes.HasPrefix(data[offset:], []byte("<![CDATA[")) {
+				text = KDLValue{RawString: string(t), Type: KDLRawStringType}
+			}
+			node := NewNode(XiKText)
+			node.AddArg(text)
+			parent.AddChild(node)
+		case xml.Comment:
+			node := NewNode(XiKComment)
+			node.AddArg(KDLValue{String: string(t), Type: KDLStringType})
+			parent.AddChild(node)
+		case xml.ProcInst:
+			parent.AddChild(xikProcInst(t))
+		case xml.Directive:
+			directive := strings.TrimSpace(string(t))
+			keyword := strings.ToLower(strings.Fields(directive + " ")[0])
+			node := NewNode("!" + keyword)
+			if rest := strings.TrimSpace(directive[len(keyword):]); len(rest) > 0 {
+				node.AddArg(KDLValue{String: rest, Type: KDLStringType})
+			}
+			parent.AddChild(node)
+		}
+		offset = dec.InputOffset()
+	}
+	if len(stack) > 1 {
+		return nil, &xml.SyntaxError{Msg: "unexpected EOF"}
+	}
+
+	dropXiKWhitespace(root)
+	return NewDocument(root.children...), nil
+}
+
+func xmlName(name xml.Name) string {
+	if len(name.Space) > 0 {
+		return name.Space + ":" + name.Local
+	}
+	return name.Local
+}
+
+// collapseXiKText turns the text of an element holding nothing else into its
+// argument, and drops the indentation between the children of any other
+// element.
+func collapseXiKText(node *Node) {
+	if len(node.children) == 1 && isXiKText(node.children[0]) {
+		node.args = node.children[0].args
+		node.children = nil
+		return
+	}
+	dropXiKWhitespace(node)
+}
+
+// dropXiKWhitespace drops the whitespace spanning lines between the children
+// of an element without any other text, which is only there to indent them.
+// Any whitespace in mixed content, and the spaces between elements on the same
+// line, e.g. `<b>bold</b> <i>italic</i>`, are kept.
+func dropXiKWhitespace(node *Node) {
+	for _, child := range node.children {
+		if isXiKText(child) && !isXiKWhitespace(child) {
+			return
+		}
+	}
+
+	var children []*Node
+	for _, child := range node.children {
+		if isXiKWhitespace(child) && strings.ContainsAny(child.args[0].String, "\r\n") {
+			continue
+		}
+		children = append(children, child)
+	}
+	node.children = children
+}
+
+func isXiKWhitespace(node *Node) bool {
+	return isXiKText(node) && node.args[0].Type == KDLStringType &&
+		len(strings.TrimSpace(node.args[0].String)) == 0
+}
+
+func isXiKText(node *Node) bool {
+	return node.name == XiKText && len(node.args) == 1
+}
+
+var xikPseudoAttrs = regexp.MustCompile(`^\s*(?:[\w.:-]+\s*=\s*(?:"[^"]*"|'[^']*')\s*)*$`)
+var xikPseudoAttr = regexp.MustCompile(`([\w.:-]+)\s*=\s*(?:"([^"]*)"|'([^']*)')`)
+
+// xikProcInst converts a processing instruction into a node, with its
+// pseudo-attributes as properties if it is made of them and with the
+// instruction as its argument otherwise.
+func xikProcInst(procInst xml.ProcInst) *Node {
+	node := NewNode("?" + procInst.Target)
+	inst := string(procInst.Inst)
+	if !xikPseudoAttrs.MatchString(inst) {
+		node.AddArg(KDLValue{String: strings.TrimSpace(inst), Type: KDLStringType})
+		return node
+	}
+	for _, match := range xikPseudoAttr.FindAllStringSubmatch(inst, -1) {
+		node.AddProp(match[1], KDLValue{String: match[2] + match[3], Type: KDLStringType})
+	}
+	return node
+}
+
+// XiKToXML converts a XML-in-KDL document back into XML. Raw strings are
+// written as CDATA sections and other values of arguments and properties as
+// they would be in KDL. The error is an *UnmarshalError naming the node if it
+// isn't valid XiK.
+func XiKToXML(doc *Document) ([]byte, error) {
+	return XiKToXMLIndent(doc, "")
+}
+
+// XiKToXMLIndent is like XiKToXML, but puts every child of an element without
+// any text on a new line indented by indent.
+func XiKToXMLIndent(doc *Document, indent string) ([]byte, error) {
+	w := &xikWriter{indent: indent}
+	for i, node := range doc.nodes {
+		if i > 0 && len(indent) > 0 {
+			w.buf.WriteString("\n")
+		}
+		err := w.node(node, node.name, 0)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return w.buf.Bytes(), nil
+}
+
+type xikWriter struct {
+	buf    bytes.Buffer
+	indent string
+}
+
+func (w *xikWriter) node(node *Node, path string, depth int) error {
+	switch {
+	case node.name == XiKText:
+		return w.text(node, path)
+	case node.name == XiKComment:
+		if len(node.props) > 0 || len(node.children) > 0 {
+			return nodeErr(KDLInvalidXiK, node, path, "a comment can only have arguments")
+		}
+		w.buf.WriteString("<!--")
+		for _, arg := range node.args {
+			s, err := xikValue(arg, node, path)
+			if err != nil {
+				return err
+			}
+			w.buf.WriteString(s)
+		}
+		w.buf.WriteString("-->")
+		return nil
+	case strings.HasPrefix(node.name, "?"):
+		return w.procInst(node, path)
+	case strings.HasPrefix(node.name, "!"):
+		if len(node.props) > 0 || len(node.children) > 0 {
+			return nodeErr(KDLInvalidXiK, node, path, "a directive can only have arguments")
+		}
+		w.buf.WriteString("<!" + strings.ToUpper(node.name[1:]))
+		for _, arg := range node.args {
+			s, err := xikValue(arg, node, path)
+			if err != nil {
+				return err
+			}
+			w.buf.WriteString(" " + s)
+		}
+		w.buf.WriteString(">")
+		return nil
+	}
+	return w.element(node, path, depth)
+}
+
+func (w *xikWriter) element(node *Node, path string, depth int) error {
+	if len(node.name) == 0 {
+		return nodeErr(KDLInvalidXiK, node, path, "an element needs a name")
+	}
+	w.buf.WriteString("<" + node.name)
+	err := w.attrs(node, path)
+	if err != nil {
+		return err
+	}
+	if len(node.args) == 0 && len(node.children) == 0 {
+		w.buf.WriteString("/>")
+		return nil
+	}
+	w.buf.WriteString(">")
+
+	for _, arg := range node.args {
+		err := w.textValue(arg, node, path)
+		if err != nil {
+			return err
+		}
+	}
+	isIndented := len(w.indent) > 0 && len(node.args) == 0
+	for _, child := range node.children {
+		isIndented = isIndented && child.name != XiKText
+	}
+	for _, child := range node.children {
+		if isIndented {
+			w.buf.WriteString("\n" + strings.Repeat(w.indent, depth+1))
+		}
+		err := w.node(child, childPath(path, child.name), depth+1)
+		if err != nil {
+			return err
+		}
+	}
+	if isIndented {
+		w.buf.WriteString("\n" + strings.Repeat(w.indent, depth))
+	}
+	w.buf.WriteString("</" + node.name + ">")
+	return nil
+}
+
+func (w *xikWriter) attrs(node *Node, path string) error {
+	for _, prop := range dedupeProps(node.props) {
+		s, err := xikValue(prop.value, node, path)
+		if err != nil {
+			return err
+		}
+		w.buf.WriteString(" " + prop.key + `="`)
+		_ = xml.EscapeText(&w.buf, []byte(s))
+		w.buf.WriteString(`"`)
+	}
+	return nil
+}
+
+func (w *xikWriter) text(node *Node, path string) error {
+	if len(node.props) > 0 || len(node.children) > 0 {
+		return nodeErr(KDLInvalidXiK, node, path, "text can only have arguments")
+	}
+	for _, arg := range node.args {
+		err := w.textValue(arg, node, path)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (w *xikWriter) textValue(value KDLValue, node *Node, path string) error {
+	s, err := xikValue(value, node, path)
+	if err != nil {
+		return err
+	}
+	if value.Type == KDLRawStringType {
+		w.buf.WriteString("<![CDATA[" + strings.ReplaceAll(s, "]]>", "]]]]><![CDATA[>") + "]]>")
+		return nil
+	}
+	// Unlike in attributes, newlines and tabs don't need escaping in text.
+	var escaped bytes.Buffer
+	_ = xml.EscapeText(&escaped, []byte(s))
+	w.buf.WriteString(xikTextEscapes.Replace(escaped.String()))
+	return nil
+}
+
+var xikTextEscapes = strings.NewReplacer("&#xA;", "\n", "&#x9;", "\t")
+
+func (w *xikWriter) procInst(node *Node, path string) error {
+	if len(node.children) > 0 {
+		return nodeErr(KDLInvalidXiK, node, path, "a processing instruction can't have children")
+	}
+	w.buf.WriteString("<" + node.name)
+	for _, arg := range node.args {
+		s, err := xikValue(arg, node, path)
+		if err != nil {
+			return err
+		}
+		w.buf.WriteString(" " + s)
+	}
+	err := w.attrs(node, path)
+	if err != nil {
+		return err
+	}
+	w.buf.WriteString("?>")
+	return nil
+}
+
+// xikValue returns the text of a value. Only null values have none.
+func xikValue(value KDLValue, node *Node, path string) (string, error) {
+	switch value.Type {
+	case KDLStringType, KDLRawStringType:
+		return value.ToString()
+	case KDLNumberType:
+		return value.NumberString(), nil
+	case KDLBoolType:
+		return value.recreateKDLValue()
+	}
+	return "", nodeErr(KDLInvalidXiK, node, path, "XML has no "+string(value.Type)+" values")
+}
@@ -0,0 +1,118 @@
+package kdlgo
+
+import (
+	"errors"
+	"testing"
+)
+
+const xikInput = `<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE project>
+<!-- build descriptor -->
+<project xmlns:m="urn:maven" m:version="4">
+  <name>kdlgo &amp; friends</name>
+  <m:script><![CDATA[if (a < b) {}]]></m:script>
+  <description>Go <em>parser</em> for KDL</description>
+  <empty/>
+  <?build fast?>
+</project>`
+
+func TestXMLToXiK(t *testing.T) {
+	doc, err := XMLToXiK([]byte(xikInput))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := doc.Format(FormatOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `?xml version="1.0" encoding="UTF-8"
+!doctype "project"
+! " build descriptor "
+project xmlns:m="urn:maven" m:version="4" {
+    name "kdlgo & friends"
+    m:script r"if (a < b) {}"
+    description {
+        - "Go "
+        em "parser"
+        - " for KDL"
+    }
+    empty
+    ?build "fast"
+}
+`
+	if s != expected {
+		t.Errorf("Expected:\n%s\nGot:\n%s", expected, s)
+	}
+
+	reparsed, err := ParseDocumentString(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	xml, err := XiKToXMLIndent(reparsed, "  ")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(xml) != xikInput {
+		t.Errorf("Expected:\n%s\nGot:\n%s", xikInput, xml)
+	}
+
+	mixed := map[string]string{
+		`<p><b>bold</b> <i>italic</i></p>`:              `<p><b>bold</b> <i>italic</i></p>`,
+		`<p>A <b>bold</b>  <i>move</i>.</p>`:            `<p>A <b>bold</b>  <i>move</i>.</p>`,
+		"<ul>\n  <li>a</li> <li>b</li>\n</ul>":          `<ul><li>a</li> <li>b</li></ul>`,
+		"<p>\n  <b>bold</b>\n  text\n  <i>it</i>\n</p>": "<p>\n  <b>bold</b>\n  text\n  <i>it</i>\n</p>",
+	}
+	for input, expected := range mixed {
+		doc, err := XMLToXiK([]byte(input))
+		if err != nil {
+			t.Fatal(err)
+		}
+		xml, err := XiKToXML(doc)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(xml) != expected {
+			t.Errorf("Expected %q to round-trip to %q, got %q", input, expected, xml)
+		}
+	}
+
+	for _, invalid := range []string{`<a>`, `<a></b>`, `</a>`, `<a b=>`} {
+		if _, err := XMLToXiK([]byte(invalid)); err == nil {
+			t.Errorf("Expected an error for %q", invalid)
+		}
+	}
+}
+
+func TestXiKToXML(t *testing.T) {
+	tests := []struct {
+		kdl      string
+		expected string
+	}{
+		{`a "x\"<y" href="/?a=1&b=2" n=1 b=true`, `<a href="/?a=1&amp;b=2" n="1" b="true">x&#34;&lt;y</a>`},
+		{`p { - "a"; - r"]]>"; br; }`, `<p>a<![CDATA[]]]]><![CDATA[>]]><br/></p>`},
+	}
+	for _, test := range tests {
+		doc, err := ParseDocumentString(test.kdl)
+		if err != nil {
+			t.Fatal(err)
+		}
+		xml, err := XiKToXML(doc)
+		if err != nil {
+			t.Errorf("%s: %s", test.kdl, err)
+			continue
+		}
+		if string(xml) != test.expected {
+			t.Errorf("Expected %s to convert to %s, got %s", test.kdl, test.expected, xml)
+		}
+	}
+
+	for _, invalid := range []string{`a null`, `- a=1`, `! { a; }`, `?x { a; }`, `"" 1`} {
+		doc, err := ParseDocumentString(invalid)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := XiKToXML(doc); !errors.Is(err, KDLInvalidXiK) {
+			t.Errorf("Expected %q to be invalid XiK, got %v", invalid, err)
+		}
+	}
+}